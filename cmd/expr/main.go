@@ -0,0 +1,121 @@
+// expr 是表达式的命令行调试工具，可以使用 JSON 或 YAML 输入执行表达式，也可以进入交互模式。
+//
+// 输入数据绑定为 this 变量，与 expr.DefaultEnv 的用法一致：
+//
+//	echo '{"value": 80}' | expr 'this.value > 60'
+//	expr -f order.yaml -var 'limit:int=100' 'this.amount < limit'
+//	expr -i -f order.json -descriptors model.pb
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+type config struct {
+	input       string
+	format      string
+	vars        stringsFlag
+	descriptors stringsFlag
+	interactive bool
+	history     string
+	expression  string
+}
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cfg, err := parseFlags(args, stderr)
+	if err != nil {
+		return err
+	}
+
+	s, err := newSession(cfg.descriptors)
+	if err != nil {
+		return err
+	}
+	for _, v := range cfg.vars {
+		if err := s.declareFlag(v); err != nil {
+			return err
+		}
+	}
+
+	// 交互模式下 stdin 用于读取命令，只有显式指定 -f 时才加载输入
+	if cfg.input != "" || !cfg.interactive {
+		this, err := readInput(cfg.input, cfg.format, stdin)
+		if err != nil {
+			return err
+		}
+		s.setThis(this)
+	}
+
+	if cfg.interactive {
+		r := newREPL(s, stdout, cfg.history)
+		if cfg.expression != "" {
+			r.exec(cfg.expression)
+		}
+		return r.run(stdin)
+	}
+	if cfg.expression == "" {
+		return errors.New("expression is required")
+	}
+	out, err := s.eval(cfg.expression)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, out)
+	return nil
+}
+
+func parseFlags(args []string, stderr io.Writer) (*config, error) {
+	cfg := &config{}
+	fs := flag.NewFlagSet("expr", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&cfg.input, "f", "", "input file bound to `this`, '-' reads from stdin (default stdin unless -i)")
+	fs.StringVar(&cfg.format, "format", "", "input format: json or yaml (default detected from file extension, json for stdin)")
+	fs.Var(&cfg.vars, "var", "declare an extra variable as 'name:type[=json value]', can be repeated")
+	fs.Var(&cfg.descriptors, "descriptors", "load protobuf types from a FileDescriptorSet file, can be repeated")
+	fs.BoolVar(&cfg.interactive, "i", false, "start the interactive REPL")
+	fs.StringVar(&cfg.history, "history", defaultHistoryFile(), "REPL history file, empty disables history persistence")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: expr [flags] <expression>")
+		fmt.Fprintln(fs.Output(), "       expr -i [flags] [expression]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.expression = strings.Join(fs.Args(), " ")
+	return cfg, nil
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".expr_history")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/zhijingtech/expr/testdata"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	yamlFile := filepath.Join(dir, "input.yaml")
+	assert.NoError(t, os.WriteFile(yamlFile, []byte("value: 3\nname: foo\n"), 0o600))
+
+	fds := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(testdata.File_testdata_model_proto),
+	}}
+	data, err := proto.Marshal(fds)
+	assert.NoError(t, err)
+	pbFile := filepath.Join(dir, "model.pb")
+	assert.NoError(t, os.WriteFile(pbFile, data, 0o600))
+
+	tests := []struct {
+		name    string
+		args    []string
+		stdin   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "json from stdin",
+			args:  []string{"this.value > 60 && this.items[1] == 2.5"},
+			stdin: `{"value": 80, "items": [1, 2.5]}`,
+			want:  "true",
+		},
+		{
+			name: "yaml from file",
+			args: []string{"-f", yamlFile, "this.name + string(this.value)"},
+			want: `"foo3"`,
+		},
+		{
+			name:  "extra variables",
+			args:  []string{"-var", "limit:int=100", "-var", "tags:list(string)=[\"a\"]", `{"ok": this.value < limit, "tags": tags}`},
+			stdin: `{"value": 80}`,
+			want:  `{"ok":true,"tags":["a"]}`,
+		},
+		{
+			name:  "proto descriptors",
+			args:  []string{"-descriptors", pbFile, "-var", `rect:testdata.Rectangle={"P1": {"X": 1}, "P2": {"X": 3}}`, "rect.P2.X - rect.P1.X"},
+			stdin: `{}`,
+			want:  "2",
+		},
+		{
+			name:    "compile error",
+			args:    []string{"dummy"},
+			stdin:   `{}`,
+			wantErr: "undeclared reference to 'dummy'",
+		},
+		{
+			name:    "invalid variable",
+			args:    []string{"-var", "limit", "limit"},
+			wantErr: `invalid variable "limit", want name:type[=value]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			err := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want+"\n", stdout.String())
+		})
+	}
+}
+
+func TestRun_REPL(t *testing.T) {
+	history := filepath.Join(t.TempDir(), "history")
+	input := filepath.Join(t.TempDir(), "input.json")
+	assert.NoError(t, os.WriteFile(input, []byte(`{"value": 3}`), 0o600))
+
+	commands := strings.Join([]string{
+		":type this.value * 2",
+		":let x = this.value * 2",
+		"x + 1",
+		":vars",
+		":foo",
+		":history",
+		":quit",
+	}, "\n")
+	var stdout bytes.Buffer
+	err := run([]string{"-i", "-f", input, "-history", history}, strings.NewReader(commands), &stdout, &bytes.Buffer{})
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"> int",
+		"> > 7",
+		"> this: map(string, dyn)",
+		"x: int",
+		"> error: unknown command :foo, type :help for help",
+		">    1  :type this.value * 2",
+		"   2  :let x = this.value * 2",
+		"   3  x + 1",
+		"   4  :vars",
+		"   5  :foo",
+		"   6  :history",
+		"> ",
+	}, "\n"), stdout.String())
+
+	data, err := os.ReadFile(history)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, strings.Count(string(data), "\n"))
+}
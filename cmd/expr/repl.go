@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const replHelp = `commands:
+  <expression>            evaluate the expression
+  :type <expression>      show the output type of the expression
+  :vars                   list declared variables
+  :let <name> = <expr>    evaluate the expression and bind the result to a variable
+  :history                list the command history
+  :help                   show this help
+  :quit                   exit the REPL`
+
+type repl struct {
+	s           *session
+	out         io.Writer
+	historyFile string
+	history     []string
+}
+
+func newREPL(s *session, out io.Writer, historyFile string) *repl {
+	r := &repl{s: s, out: out, historyFile: historyFile}
+	if historyFile != "" {
+		if data, err := os.ReadFile(historyFile); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line = strings.TrimSpace(line); line != "" {
+					r.history = append(r.history, line)
+				}
+			}
+		}
+	}
+	return r
+}
+
+func (r *repl) run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.out, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(r.out)
+			return scanner.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if line == ":quit" || line == ":q" {
+			return nil
+		}
+		r.addHistory(line)
+		r.exec(line)
+	}
+}
+
+// exec 执行一行命令，错误直接输出，不会退出 REPL
+func (r *repl) exec(line string) {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	var err error
+	switch cmd {
+	case ":help":
+		fmt.Fprintln(r.out, replHelp)
+	case ":vars":
+		for _, v := range r.s.variables() {
+			fmt.Fprintln(r.out, v)
+		}
+	case ":history":
+		for i, h := range r.history {
+			fmt.Fprintf(r.out, "%4d  %s\n", i+1, h)
+		}
+	case ":type":
+		err = r.typeOf(arg)
+	case ":let":
+		err = r.let(arg)
+	default:
+		if strings.HasPrefix(cmd, ":") {
+			err = fmt.Errorf("unknown command %s, type :help for help", cmd)
+			break
+		}
+		var out string
+		if out, err = r.s.eval(line); err == nil {
+			fmt.Fprintln(r.out, out)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(r.out, "error:", err)
+	}
+}
+
+func (r *repl) typeOf(expression string) error {
+	e, err := r.s.compile(expression)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, e.OutputType())
+	return nil
+}
+
+func (r *repl) let(arg string) error {
+	name, expression, ok := strings.Cut(arg, "=")
+	name, expression = strings.TrimSpace(name), strings.TrimSpace(expression)
+	if !ok || name == "" || expression == "" {
+		return fmt.Errorf("usage: :let <name> = <expression>")
+	}
+	if name == "this" {
+		return fmt.Errorf("cannot redeclare this")
+	}
+	return r.s.let(name, expression)
+}
+
+func (r *repl) addHistory(line string) {
+	r.history = append(r.history, line)
+	if r.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(r.historyFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
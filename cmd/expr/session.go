@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"gopkg.in/yaml.v3"
+
+	"github.com/zhijingtech/expr"
+)
+
+var jsonValueType = reflect.TypeOf(&structpb.Value{})
+
+// session 保存命令行工具的环境、变量声明和变量的值
+type session struct {
+	base   *expr.Env
+	env    *expr.Env
+	files  *protoregistry.Files
+	vars   map[string]*expr.Type
+	values map[string]any
+}
+
+func newSession(descriptors []string) (*session, error) {
+	s := &session{
+		files:  new(protoregistry.Files),
+		vars:   map[string]*expr.Type{},
+		values: map[string]any{"this": map[string]any{}},
+	}
+	opts := []expr.Option{expr.UseThisVariable()}
+	for _, path := range descriptors {
+		fds, err := loadDescriptorSet(path)
+		if err != nil {
+			return nil, err
+		}
+		files, err := protodesc.NewFiles(fds)
+		if err != nil {
+			return nil, fmt.Errorf("load descriptors %s: %w", path, err)
+		}
+		var rangeErr error
+		files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+			if _, err := s.files.FindFileByPath(fd.Path()); err == nil {
+				return true
+			}
+			rangeErr = s.files.RegisterFile(fd)
+			return rangeErr == nil
+		})
+		if rangeErr != nil {
+			return nil, fmt.Errorf("load descriptors %s: %w", path, rangeErr)
+		}
+		opts = append(opts, expr.TypeDescs(fds))
+	}
+	base, err := expr.NewEnv(opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.base, s.env = base, base
+	s.vars["this"] = expr.MapType(expr.StringType, expr.DynType)
+	return s, nil
+}
+
+func loadDescriptorSet(path string) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	fds := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, fds); err != nil {
+		return nil, fmt.Errorf("load descriptors %s: %w", path, err)
+	}
+	return fds, nil
+}
+
+func (s *session) setThis(this any) {
+	s.values["this"] = this
+}
+
+// declareFlag 解析 name:type[=json value] 格式的变量声明
+func (s *session) declareFlag(decl string) error {
+	decl, raw, hasValue := strings.Cut(decl, "=")
+	name, typeName, ok := strings.Cut(decl, ":")
+	name, typeName = strings.TrimSpace(name), strings.TrimSpace(typeName)
+	if !ok || name == "" || typeName == "" {
+		return fmt.Errorf("invalid variable %q, want name:type[=value]", decl)
+	}
+	t, err := parseType(typeName)
+	if err != nil {
+		return err
+	}
+	var value any
+	if hasValue {
+		if value, err = s.decodeValue(t, []byte(raw)); err != nil {
+			return fmt.Errorf("variable %s: %w", name, err)
+		}
+	}
+	return s.declare(name, t, value)
+}
+
+// declare 声明变量，同名变量会被覆盖
+func (s *session) declare(name string, t *expr.Type, value any) error {
+	vars := make(map[string]*expr.Type, len(s.vars)+1)
+	for n, vt := range s.vars {
+		vars[n] = vt
+	}
+	vars[name] = t
+
+	opts := make([]expr.Option, 0, len(vars))
+	for n, vt := range vars {
+		if n == "this" {
+			continue
+		}
+		opts = append(opts, expr.Variable(n, vt))
+	}
+	env, err := s.base.Extend(opts...)
+	if err != nil {
+		return err
+	}
+	s.env, s.vars = env, vars
+	if value != nil {
+		s.values[name] = value
+	} else {
+		delete(s.values, name)
+	}
+	return nil
+}
+
+// variables 返回按名称排序的变量声明
+func (s *session) variables() []string {
+	names := make([]string, 0, len(s.vars))
+	for n := range s.vars {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for i, n := range names {
+		names[i] = fmt.Sprintf("%s: %s", n, s.vars[n])
+		if _, ok := s.values[n]; !ok {
+			names[i] += " (unset)"
+		}
+	}
+	return names
+}
+
+func (s *session) compile(expression string) (*expr.Expr, error) {
+	return expr.NewExpr(expression, s.env)
+}
+
+// eval 执行表达式并将结果格式化为 JSON
+func (s *session) eval(expression string) (string, error) {
+	e, err := s.compile(expression)
+	if err != nil {
+		return "", err
+	}
+	out, err := e.WithDecoder(expr.DecoderFunc(formatVal)).Eval(s.values)
+	if err != nil {
+		return "", err
+	}
+	return out.(string), nil
+}
+
+// let 执行表达式，并将结果绑定为新的变量
+func (s *session) let(name, expression string) error {
+	e, err := s.compile(expression)
+	if err != nil {
+		return err
+	}
+	v, err := e.WithDecoder(expr.DecoderFunc(func(v expr.Val) (any, error) {
+		return v, nil
+	})).Eval(s.values)
+	if err != nil {
+		return err
+	}
+	return s.declare(name, e.OutputType(), v)
+}
+
+func formatVal(v expr.Val) (any, error) {
+	if jv, err := v.ConvertToNative(jsonValueType); err == nil {
+		if out, err := protojson.Marshal(jv.(proto.Message)); err == nil {
+			// protojson 的输出格式不稳定，重新格式化为紧凑的 JSON
+			var buf bytes.Buffer
+			if json.Compact(&buf, out) == nil {
+				return buf.String(), nil
+			}
+			return string(out), nil
+		}
+	}
+	return fmt.Sprint(v.Value()), nil
+}
+
+// decodeValue 将 JSON 解析为 t 类型变量的值，protobuf 消息类型使用已加载的描述符解析
+func (s *session) decodeValue(t *expr.Type, data []byte) (any, error) {
+	if d, err := s.files.FindDescriptorByName(protoreflect.FullName(t.TypeName())); err == nil {
+		md, ok := d.(protoreflect.MessageDescriptor)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a message", t.TypeName())
+		}
+		msg := dynamicpb.NewMessage(md)
+		if err := protojson.Unmarshal(data, msg); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+	return decodeJSON(bytes.NewReader(data))
+}
+
+// readInput 读取 this 变量的值，path 为空或 "-" 时从 stdin 读取
+func readInput(path, format string, stdin io.Reader) (any, error) {
+	r := stdin
+	if path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+		if format == "" {
+			format = strings.TrimPrefix(filepath.Ext(path), ".")
+		}
+	}
+	switch strings.ToLower(format) {
+	case "", "json":
+		return decodeJSON(r)
+	case "yaml", "yml":
+		var v any
+		if err := yaml.NewDecoder(r).Decode(&v); err != nil && err != io.EOF {
+			return nil, err
+		}
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported input format: %s", format)
+}
+
+// decodeJSON 解析 JSON，整数解析为 int64，其他数字解析为 float64
+func decodeJSON(r io.Reader) (any, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return normalizeNumbers(v), nil
+}
+
+func normalizeNumbers(v any) any {
+	switch t := v.(type) {
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		f, _ := t.Float64()
+		return f
+	case map[string]any:
+		for k, e := range t {
+			t[k] = normalizeNumbers(e)
+		}
+	case []any:
+		for i, e := range t {
+			t[i] = normalizeNumbers(e)
+		}
+	}
+	return v
+}
+
+// parseType 解析 cel 类型名称，支持基础类型、list(T)、map(K, V) 以及 protobuf 消息的全名
+func parseType(name string) (*expr.Type, error) {
+	name = strings.TrimSpace(name)
+	switch name {
+	case "bool":
+		return expr.BoolType, nil
+	case "int":
+		return expr.IntType, nil
+	case "uint":
+		return expr.UintType, nil
+	case "double":
+		return expr.DoubleType, nil
+	case "string":
+		return expr.StringType, nil
+	case "bytes":
+		return expr.BytesType, nil
+	case "dyn", "any":
+		return expr.DynType, nil
+	case "timestamp":
+		return expr.TimestampType, nil
+	case "duration":
+		return expr.DurationType, nil
+	case "list":
+		return expr.ListType(expr.DynType), nil
+	case "map":
+		return expr.MapType(expr.StringType, expr.DynType), nil
+	}
+	if inner, ok := strings.CutPrefix(name, "list("); ok && strings.HasSuffix(inner, ")") {
+		elem, err := parseType(strings.TrimSuffix(inner, ")"))
+		if err != nil {
+			return nil, err
+		}
+		return expr.ListType(elem), nil
+	}
+	if inner, ok := strings.CutPrefix(name, "map("); ok && strings.HasSuffix(inner, ")") {
+		params := splitTopLevel(strings.TrimSuffix(inner, ")"))
+		if len(params) != 2 {
+			return nil, fmt.Errorf("invalid map type: %s", name)
+		}
+		key, err := parseType(params[0])
+		if err != nil {
+			return nil, err
+		}
+		val, err := parseType(params[1])
+		if err != nil {
+			return nil, err
+		}
+		return expr.MapType(key, val), nil
+	}
+	if name == "" || strings.ContainsAny(name, "(), ") {
+		return nil, fmt.Errorf("invalid type: %s", name)
+	}
+	return expr.ObjectType(name), nil
+}
+
+// splitTopLevel 按不在括号内的逗号分割类型参数
+func splitTopLevel(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
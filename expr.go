@@ -1,29 +1,64 @@
 package expr
 
 import (
+	"context"
 	"errors"
-	"reflect"
+	"fmt"
+	"sync"
 
 	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
 )
 
 type (
-	Env cel.Env
+	// Env 表达式的编译和执行环境，除 cel 的声明外还保存了本包的扩展配置
+	Env struct {
+		env     *cel.Env
+		decoder Decoder
+		// costLimit 执行代价的上限，为 0 时不限制
+		costLimit uint64
+		// maxEstimatedCost 编译时静态估算的最大代价上限，为 0 时不估算
+		maxEstimatedCost uint64
+		sizeHints        SizeHints
+		// lenient 宽松模式的配置，为 nil 时不启用
+		lenient *lenientConfig
+		// numericCoercion 是否自动转换数值类型
+		numericCoercion bool
+		// clock 时间函数中 now() 使用的时钟，为 nil 时使用系统时间
+		clock Clock
+	}
 	// 定义一个接口，使用类型集来限制为基础类型
 	Expr struct {
-		p cel.Program
+		env     *Env
+		ast     *cel.Ast
+		p       cel.Program
+		decoder Decoder
+		// partial 部分求值使用的执行计划，首次使用时创建
+		partial *lazyProgram
+		// traced 记录每个子表达式取值的执行计划，首次使用时创建
+		traced *lazyProgram
+	}
+
+	// lazyProgram 按需创建的执行计划，用于需要额外执行选项的场景，避免影响 Eval 的性能
+	lazyProgram struct {
+		once sync.Once
+		p    cel.Program
+		err  error
 	}
 )
 
 var (
 	ErrEnvNil = errors.New("env is nil")
+	// ErrEvalInterrupted 表达式执行因 context 取消或超时而中断，可通过 errors.Is 与
+	// context.Canceled、context.DeadlineExceeded 进一步区分
+	ErrEvalInterrupted = errors.New("evaluation interrupted")
 
 	DefaultEnv, _ = NewEnv(UseThisVariable())
-
-	toSliceAny  = reflect.TypeOf([]any{})
-	toMapAnyAny = reflect.TypeOf(map[any]any{})
 )
 
+// interruptCheckFrequency 推导式（map、filter、all 等）每迭代多少次检查一次 context 是否结束
+const interruptCheckFrequency = 100
+
 // UseThisVariable 注册map类型的this变量，方便在表达式中操作this数据
 func UseThisVariable() Option {
 	return cel.Variable("this", cel.MapType(cel.StringType, cel.DynType))
@@ -34,67 +69,220 @@ func WrapThisVariable(this map[string]any) map[string]any {
 }
 
 func NewEnv(opts ...Option) (*Env, error) {
+	// 记录宏调用，以便从语法树还原出包含 all、exists 等宏的源码
+	opts = append([]Option{cel.EnableMacroCallTracking()}, opts...)
 	env, err := cel.NewEnv(opts...)
-	return (*Env)(env), err
+	if err != nil {
+		return nil, err
+	}
+	return FromCelEnv(env), nil
+}
+
+// FromCelEnv 将 cel 环境包装为 Env，替代原先 Env 为 cel.Env 的类型定义时的 (*Env)(celEnv) 转换，
+// 包装后的环境使用 DefaultDecoder，不包含 WithLenient 等本包的扩展配置
+func FromCelEnv(env *cel.Env) *Env {
+	if env == nil {
+		return nil
+	}
+	return &Env{env: env, decoder: DefaultDecoder}
+}
+
+// CelEnv 返回底层的 cel 环境，替代原先 Env 为 cel.Env 的类型定义时的 (*cel.Env)(env) 转换
+func (e *Env) CelEnv() *cel.Env {
+	return e.env
 }
 
 func (e *Env) Extend(opts ...Option) (*Env, error) {
-	celEnv := (*cel.Env)(e)
-	newEnv, err := celEnv.Extend(opts...)
+	newEnv, err := e.env.Extend(opts...)
 	if err != nil {
 		return nil, err
 	}
-	return (*Env)(newEnv), nil
+	if e.numericCoercion {
+		if newEnv, err = coerceFunctionArgs(newEnv, e.env); err != nil {
+			return nil, err
+		}
+	}
+	ext := e.clone()
+	ext.env = newEnv
+	return ext, nil
+}
+
+// WithDecoder 返回使用 decoder 解析执行结果的新环境，基于该环境创建的表达式默认使用此解析器
+func (e *Env) WithDecoder(decoder Decoder) *Env {
+	ext := e.clone()
+	ext.decoder = decoder
+	return ext
+}
+
+func (e *Env) clone() *Env {
+	ext := *e
+	return &ext
 }
 
 func NewExpr(expression string, env ...*Env) (*Expr, error) {
-	var _env *Env
-	if len(env) == 0 {
-		_env = DefaultEnv
-	} else {
-		_env = env[0]
+	_env, err := selectEnv(env)
+	if err != nil {
+		return nil, wrapCompileError(expression, CodeUnknown, err)
 	}
-	celEnv := (*cel.Env)(_env)
-	ast, issues := celEnv.Compile(expression)
+	ast, issues := _env.env.Parse(expression)
 	if issues.Err() != nil {
-		return nil, issues.Err()
+		return nil, newCompileError(common.NewTextSource(expression), issues, true)
+	}
+	checked, issues := _env.check(ast)
+	if issues.Err() != nil {
+		return nil, newCompileError(ast.Source(), issues, false)
+	}
+	return newExpr(_env, checked)
+}
+
+// check 对语法树做类型检查，自动转换数值类型时会改写比较不同数值类型的 == 和 !=
+func (e *Env) check(parsed *cel.Ast) (*cel.Ast, *cel.Issues) {
+	checked, issues := e.env.Check(parsed)
+	if issues.Err() != nil && e.numericCoercion && coerceEquality(parsed, issues) {
+		return e.env.Check(parsed)
+	}
+	return checked, issues
+}
+
+// selectEnv 返回可选参数中的环境，未指定时使用 DefaultEnv
+func selectEnv(env []*Env) (*Env, error) {
+	_env := DefaultEnv
+	if len(env) != 0 {
+		_env = env[0]
+	}
+	if _env == nil {
+		return nil, ErrEnvNil
 	}
+	return _env, nil
+}
 
-	program, err := celEnv.Program(ast)
+func newExpr(env *Env, ast *cel.Ast) (*Expr, error) {
+	var source string
+	if src := ast.Source(); src != nil {
+		source = src.Content()
+	}
+	if env.maxEstimatedCost > 0 {
+		if err := env.checkEstimatedCost(ast); err != nil {
+			return nil, wrapCompileError(source, CodeCostLimitExceeded, err)
+		}
+	}
+	program, err := env.env.Program(ast, env.programOptions()...)
 	if err != nil {
-		return nil, err
+		return nil, wrapCompileError(source, issueCode(err.Error(), false), err)
+	}
+	return &Expr{
+		env:     env,
+		ast:     ast,
+		p:       program,
+		decoder: env.decoder,
+		partial: &lazyProgram{},
+		traced:  &lazyProgram{},
+	}, nil
+}
+
+// programOptions 返回环境配置对应的执行选项
+func (e *Env) programOptions() []cel.ProgramOption {
+	opts := []cel.ProgramOption{cel.InterruptCheckFrequency(interruptCheckFrequency)}
+	if e.costLimit > 0 {
+		opts = append(opts, cel.CostLimit(e.costLimit))
+	}
+	if e.lenient != nil {
+		opts = append(opts, cel.CustomDecorator(e.lenient.decorate))
+	}
+	if e.numericCoercion {
+		opts = append(opts, cel.CustomDecorator(decorateNumeric))
+	}
+	if e.env.HasLibrary(timeLibName) {
+		opts = append(opts, cel.CustomDecorator(e.decorateClock))
 	}
-	return &Expr{p: program}, nil
+	return opts
+}
+
+func (l *lazyProgram) get(e *Expr, opts ...cel.ProgramOption) (cel.Program, error) {
+	l.once.Do(func() {
+		l.p, l.err = e.env.env.Program(e.ast, append(e.env.programOptions(), opts...)...)
+	})
+	return l.p, l.err
+}
+
+// Ast 返回表达式经类型检查后的语法树
+func (e *Expr) Ast() *Ast {
+	return e.ast
+}
+
+// String 返回表达式的源码，从编译结果加载的表达式返回由语法树还原的源码
+func (e *Expr) String() string {
+	if src := e.ast.Source(); src != nil {
+		return src.Content()
+	}
+	s, err := cel.AstToString(e.ast)
+	if err != nil {
+		return ""
+	}
+	return s
+}
+
+// OutputType 返回表达式经类型检查后的输出类型
+func (e *Expr) OutputType() *Type {
+	return e.ast.OutputType()
+}
+
+// WithDecoder 返回使用 decoder 解析执行结果的表达式，与原表达式共享编译结果
+func (e *Expr) WithDecoder(decoder Decoder) *Expr {
+	ex := *e
+	ex.decoder = decoder
+	return &ex
 }
 
 func (e *Expr) Eval(input any) (any, error) {
-	ev, _, err := e.p.Eval(input)
-	if ev == nil || err != nil {
+	ev, det, err := e.p.Eval(input)
+	if err != nil {
+		return nil, e.evalErr(err, det)
+	}
+	if ev == nil {
+		return nil, nil
+	}
+	return e.decode(ev)
+}
+
+// EvalContext 在 ctx 的约束下执行表达式，执行在调用方的 goroutine 中进行，返回时不会遗留后台任务。
+// ctx 被取消或超时后，推导式会在下一次检查点中止，返回包装了 ErrEvalInterrupted 和 ctx.Err() 的错误；
+// 自定义函数不会被强行中断，耗时的函数应通过 GoFunction 注册并接收 context.Context，在 ctx 结束时尽快返回。
+func (e *Expr) EvalContext(ctx context.Context, input any) (any, error) {
+	if ctx == nil {
+		return nil, errors.New("context is nil")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, interruptedErr(err)
+	}
+	return e.evalContext(ctx, input)
+}
+
+func (e *Expr) evalContext(ctx context.Context, input any) (any, error) {
+	input, err := contextInput(ctx, input)
+	if err != nil {
 		return nil, err
 	}
-	v := ev.Value()
-	switch v.(type) {
-	case map[Val]Val:
-		tmp, err := ev.ConvertToNative(toMapAnyAny)
-		if err == nil {
-			v = tmp
-		}
-	case []Val:
-		tmp, err := ev.ConvertToNative(toSliceAny)
-		if err == nil {
-			v = tmp
-		}
+	ev, det, err := e.p.ContextEval(ctx, input)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, interruptedErr(ctxErr)
+	}
+	if err != nil {
+		return nil, e.evalErr(err, det)
+	}
+	if ev == nil {
+		return nil, nil
 	}
-	return v, nil
+	return e.decode(ev)
 }
 
-// func (e *Expr) ContextEval(ctx context.Context, input any) (any, error) {
-// 	result, _, err := e.p.ContextEval(ctx, input)
-// 	if err != nil {
-// 		return nil, err
-// 	}
-// 	if result == nil {
-// 		return nil, nil
-// 	}
-// 	return result.Value(), nil
-// }
+func (e *Expr) decode(ev Val) (any, error) {
+	if e.decoder == nil {
+		return DefaultDecoder.Decode(ev)
+	}
+	return e.decoder.Decode(ev)
+}
+
+func interruptedErr(cause error) error {
+	return newEvalError(KindCancelled, fmt.Errorf("%w: %w", ErrEvalInterrupted, cause))
+}
@@ -0,0 +1,324 @@
+package expr
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/parser"
+)
+
+// Aggregations 列表聚合函数：
+//   - sum()、avg()：list(int) 和 list(uint) 的 sum 结果为 int 和 uint，溢出时报错，list(double) 的结果为 double，avg 的结果总是 double，
+//     元素类型不同时按 WithNumericCoercion 的规则转换；
+//   - min()、max()：返回最小、最大的元素，元素需可比较；
+//   - count()：元素个数，count(x, p) 为满足条件 p 的元素个数；
+//   - distinct()：去重并保留元素第一次出现的顺序；
+//   - sumBy(x, e)、avgBy(x, e)：对每个元素计算 e 后求和、求平均值；
+//   - minBy(x, e)、maxBy(x, e)：返回 e 最小、最大的元素，多个元素相同时返回第一个；
+//   - sortBy(x, e)：按 e 稳定排序；
+//   - groupBy(x, e)：按 e 分组，返回 e 到元素列表的 map，e 需为 int、uint、string 或 bool。
+//
+// 空列表的 avg、min、max、minBy 和 maxBy 报错；静态类型为 list(dyn) 的空列表的 sum 按 Go 切片的元素类型返回 0、0u 或 0.0，
+// 元素类型无法确定时（如 []any 或 sumBy 的结果）返回 0。
+func Aggregations() Option {
+	return cel.Lib(aggregationLib{})
+}
+
+type aggregationLib struct{}
+
+func (aggregationLib) LibraryName() string {
+	return "expr.aggregations"
+}
+
+func (aggregationLib) CompileOptions() []cel.EnvOption {
+	paramA, paramB := cel.TypeParamType("A"), cel.TypeParamType("B")
+	listA, listB := cel.ListType(paramA), cel.ListType(paramB)
+	return []cel.EnvOption{
+		cel.Macros(
+			cel.ReceiverMacro("sumBy", 2, aggregateByMacro("sum")),
+			cel.ReceiverMacro("avgBy", 2, aggregateByMacro("avg")),
+			cel.ReceiverMacro("minBy", 2, associatedKeysMacro("@minByKeys")),
+			cel.ReceiverMacro("maxBy", 2, associatedKeysMacro("@maxByKeys")),
+			cel.ReceiverMacro("sortBy", 2, associatedKeysMacro("@sortByKeys")),
+			cel.ReceiverMacro("groupBy", 2, associatedKeysMacro("@groupByKeys")),
+			cel.ReceiverMacro("count", 2, countMacro),
+		),
+		Function("sum",
+			MemberOverload("list_int_sum", []*Type{ListType(IntType)}, IntType, UnaryBinding(sumList(types.IntZero))),
+			MemberOverload("list_uint_sum", []*Type{ListType(UintType)}, UintType, UnaryBinding(sumList(types.Uint(0)))),
+			MemberOverload("list_double_sum", []*Type{ListType(DoubleType)}, DoubleType, UnaryBinding(sumList(types.Double(0)))),
+		),
+		Function("avg",
+			MemberOverload("list_int_avg", []*Type{ListType(IntType)}, DoubleType, UnaryBinding(avgList)),
+			MemberOverload("list_uint_avg", []*Type{ListType(UintType)}, DoubleType, UnaryBinding(avgList)),
+			MemberOverload("list_double_avg", []*Type{ListType(DoubleType)}, DoubleType, UnaryBinding(avgList)),
+		),
+		Function("min", MemberOverload("list_min", []*Type{listA}, paramA, UnaryBinding(extremum("min", types.IntNegOne)))),
+		Function("max", MemberOverload("list_max", []*Type{listA}, paramA, UnaryBinding(extremum("max", types.IntOne)))),
+		Function("count", MemberOverload("list_count", []*Type{listA}, IntType, UnaryBinding(func(list Val) Val {
+			return list.(traits.Sizer).Size()
+		}))),
+		// 与 cel-go 列表扩展库的 distinct 使用相同的重载，两者可以同时引入
+		Function("distinct", MemberOverload("list_distinct", []*Type{listA}, listA, UnaryBinding(distinctList))),
+		Function("@minByKeys", MemberOverload("list_minByKeys", []*Type{listA, listB}, paramA, BinaryBinding(extremumByKeys("minBy", types.IntNegOne)))),
+		Function("@maxByKeys", MemberOverload("list_maxByKeys", []*Type{listA, listB}, paramA, BinaryBinding(extremumByKeys("maxBy", types.IntOne)))),
+		Function("@sortByKeys", MemberOverload("list_sortByKeys", []*Type{listA, listB}, listA, BinaryBinding(sortByKeys))),
+		Function("@groupByKeys", MemberOverload("list_groupByKeys", []*Type{listA, listB}, cel.MapType(paramB, listA), BinaryBinding(groupByKeys))),
+	}
+}
+
+func (aggregationLib) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+// aggregateByMacro 将 list.fnBy(x, e) 展开为 list.map(x, e).fn()
+func aggregateByMacro(fn string) cel.MacroFactory {
+	return func(meh cel.MacroExprFactory, target ast.Expr, args []ast.Expr) (ast.Expr, *cel.Error) {
+		mapped, err := parser.MakeMap(meh, target, args)
+		if err != nil {
+			return nil, err
+		}
+		return meh.NewMemberCall(fn, mapped), nil
+	}
+}
+
+// associatedKeysMacro 将 list.xxxBy(x, e) 展开为 cel.bind(@input, list, @input.fn(@input.map(x, e)))，列表只计算一次
+func associatedKeysMacro(fn string) cel.MacroFactory {
+	return func(meh cel.MacroExprFactory, target ast.Expr, args []ast.Expr) (ast.Expr, *cel.Error) {
+		input := meh.NewIdent("@__aggregate_input__")
+		keys, err := parser.MakeMap(meh, meh.Copy(input), args)
+		if err != nil {
+			return nil, err
+		}
+		call := meh.NewMemberCall(fn, meh.Copy(input), keys)
+		return meh.NewComprehension(meh.NewList(), "#unused", input.AsIdent(), target, meh.NewLiteral(types.False), input, call), nil
+	}
+}
+
+// countMacro 将 list.count(x, p) 展开为 list.filter(x, p).count()
+func countMacro(meh cel.MacroExprFactory, target ast.Expr, args []ast.Expr) (ast.Expr, *cel.Error) {
+	filtered, err := parser.MakeFilter(meh, target, args)
+	if err != nil {
+		return nil, err
+	}
+	return meh.NewMemberCall("count", filtered), nil
+}
+
+func sumList(zero Val) UnaryOp {
+	return func(list Val) Val {
+		var sum Val
+		it := list.(traits.Lister).Iterator()
+		for it.HasNext() == types.True {
+			v := it.Next()
+			if numericType(v) == nil {
+				return types.NewErr("sum: unsupported element type '%s'", v.Type().TypeName())
+			}
+			if sum == nil {
+				sum = v
+				continue
+			}
+			lhs, rhs := promoteNumbers(sum, v)
+			if types.IsError(lhs) {
+				return lhs
+			}
+			if types.IsError(rhs) {
+				return rhs
+			}
+			if sum = lhs.(traits.Adder).Add(rhs); types.IsError(sum) {
+				return sum
+			}
+		}
+		if sum == nil {
+			return emptySum(list, zero)
+		}
+		return sum
+	}
+}
+
+// emptySum 返回空列表的和。list(dyn) 的调用在执行时总是匹配 list_int_sum，
+// 此时按 Go 切片的元素类型确定结果的类型，如空的 []float64 为 0.0，无法确定时返回 zero
+func emptySum(list, zero Val) Val {
+	rv := reflect.ValueOf(list.Value())
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return zero
+	}
+	switch rv.Type().Elem().Kind() {
+	case reflect.Float32, reflect.Float64:
+		return types.Double(0)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return types.Uint(0)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return types.IntZero
+	}
+	return zero
+}
+
+func avgList(list Val) Val {
+	var sum float64
+	n := 0
+	it := list.(traits.Lister).Iterator()
+	for it.HasNext() == types.True {
+		v := it.Next()
+		if numericType(v) == nil {
+			return types.NewErr("avg: unsupported element type '%s'", v.Type().TypeName())
+		}
+		sum += float64(v.ConvertToType(types.DoubleType).(types.Double))
+		n++
+	}
+	if n == 0 {
+		return types.NewErr("avg: empty list")
+	}
+	return types.Double(sum / float64(n))
+}
+
+// extremum 返回比较结果为 want 的极值元素
+func extremum(name string, want types.Int) UnaryOp {
+	return func(list Val) Val {
+		var result Val
+		it := list.(traits.Lister).Iterator()
+		for it.HasNext() == types.True {
+			v := it.Next()
+			if result == nil {
+				result = v
+				continue
+			}
+			cmp, err := compareVals(v, result)
+			if err != nil {
+				return err
+			}
+			if cmp == want {
+				result = v
+			}
+		}
+		if result == nil {
+			return types.NewErr("%s: empty list", name)
+		}
+		return result
+	}
+}
+
+func extremumByKeys(name string, want types.Int) BinaryOp {
+	return func(list, keys Val) Val {
+		elems, keyVals, err := associatedKeys(name, list, keys)
+		if err != nil {
+			return err
+		}
+		if len(elems) == 0 {
+			return types.NewErr("%s: empty list", name)
+		}
+		result := 0
+		for i := 1; i < len(elems); i++ {
+			cmp, err := compareVals(keyVals[i], keyVals[result])
+			if err != nil {
+				return err
+			}
+			if cmp == want {
+				result = i
+			}
+		}
+		return elems[result]
+	}
+}
+
+func sortByKeys(list, keys Val) Val {
+	elems, keyVals, err := associatedKeys("sortBy", list, keys)
+	if err != nil {
+		return err
+	}
+	indexes := make([]int, len(elems))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	var sortErr Val
+	sort.SliceStable(indexes, func(i, j int) bool {
+		cmp, err := compareVals(keyVals[indexes[i]], keyVals[indexes[j]])
+		if err != nil {
+			sortErr = err
+			return false
+		}
+		return cmp == types.IntNegOne
+	})
+	if sortErr != nil {
+		return sortErr
+	}
+	sorted := make([]Val, len(elems))
+	for i, idx := range indexes {
+		sorted[i] = elems[idx]
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, sorted)
+}
+
+func groupByKeys(list, keys Val) Val {
+	elems, keyVals, err := associatedKeys("groupBy", list, keys)
+	if err != nil {
+		return err
+	}
+	groups := map[Val][]Val{}
+	for i, k := range keyVals {
+		switch k.(type) {
+		case types.Int, types.Uint, types.String, types.Bool:
+		default:
+			return types.NewErr("groupBy: unsupported key type '%s'", k.Type().TypeName())
+		}
+		groups[k] = append(groups[k], elems[i])
+	}
+	result := make(map[Val]Val, len(groups))
+	for k, group := range groups {
+		result[k] = types.NewRefValList(types.DefaultTypeAdapter, group)
+	}
+	return types.NewRefValMap(types.DefaultTypeAdapter, result)
+}
+
+func distinctList(list Val) Val {
+	var unique []Val
+	it := list.(traits.Lister).Iterator()
+	for it.HasNext() == types.True {
+		v := it.Next()
+		seen := false
+		for _, u := range unique {
+			if u.Equal(v) == types.True {
+				seen = true
+				break
+			}
+		}
+		if !seen {
+			unique = append(unique, v)
+		}
+	}
+	return types.NewRefValList(types.DefaultTypeAdapter, unique)
+}
+
+// associatedKeys 返回列表的元素和按元素计算的 key，两者一一对应
+func associatedKeys(name string, list, keys Val) ([]Val, []Val, Val) {
+	elems, keyVals := listVals(list), listVals(keys)
+	if len(elems) != len(keyVals) {
+		return nil, nil, types.NewErr("%s: expected %d keys, got %d", name, len(elems), len(keyVals))
+	}
+	return elems, keyVals, nil
+}
+
+func listVals(list Val) []Val {
+	var vals []Val
+	it := list.(traits.Lister).Iterator()
+	for it.HasNext() == types.True {
+		vals = append(vals, it.Next())
+	}
+	return vals
+}
+
+// compareVals 比较两个值，不可比较时返回错误
+func compareVals(lhs, rhs Val) (types.Int, Val) {
+	cmp, ok := lhs.(traits.Comparer)
+	if !ok {
+		return 0, types.NewErr("no such overload: %s < %s", lhs.Type().TypeName(), rhs.Type().TypeName())
+	}
+	out := cmp.Compare(rhs)
+	if types.IsError(out) {
+		return 0, out
+	}
+	return out.(types.Int), nil
+}
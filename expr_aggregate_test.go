@@ -0,0 +1,139 @@
+package expr
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregations(t *testing.T) {
+	env, err := DefaultEnv.Extend(Aggregations())
+	if !assert.NoError(t, err) {
+		return
+	}
+	input := WrapThisVariable(map[string]any{
+		"items": []any{
+			map[string]any{"name": "a", "price": 3.5, "qty": 2, "kind": "x"},
+			map[string]any{"name": "b", "price": 1.0, "qty": 5, "kind": "y"},
+			map[string]any{"name": "c", "price": 2.5, "qty": 1, "kind": "x"},
+		},
+		"max":     math.MaxInt64,
+		"doubles": []float64{},
+		"uints":   []uint32{},
+		"ints":    []int{},
+		"empty":   []any{},
+		"prices":  []float64{1.5, 2},
+	})
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{`[1, 2, 3].sum()`, int64(6)},
+		{`[1u, 2u].sum()`, uint64(3)},
+		{`[1.5, 2.5].sum()`, 4.0},
+		{`[].sum()`, int64(0)},
+		// list(dyn) 的空列表按 Go 切片的元素类型确定结果类型
+		{`this.doubles.sum()`, 0.0},
+		{`this.uints.sum()`, uint64(0)},
+		{`this.ints.sum()`, int64(0)},
+		{`this.empty.sum()`, int64(0)},
+		{`this.prices.sum()`, 3.5},
+		{`[1, 2].avg()`, 1.5},
+		{`[3, 1, 2].min()`, int64(1)},
+		{`["b", "c", "a"].max()`, "c"},
+		{`[1, 2, 3].count()`, int64(3)},
+		{`[1, 2, 3].count(x, x > 1)`, int64(2)},
+		{`[1, 2, 1, 3].distinct()`, []any{int64(1), int64(2), int64(3)}},
+		{`this.items.sumBy(i, i.price)`, 7.0},
+		{`this.items.sumBy(i, i.qty)`, int64(8)},
+		{`this.items.avgBy(i, i.qty)`, 8.0 / 3},
+		{`this.items.minBy(i, i.price).name`, "b"},
+		{`this.items.maxBy(i, i.qty).name`, "b"},
+		{`this.items.sortBy(i, i.price).map(i, i.name)`, []any{"b", "c", "a"}},
+		{`this.items.sortBy(i, i.kind).map(i, i.name)`, []any{"a", "c", "b"}},
+		{`this.items.groupBy(i, i.kind).x.map(i, i.name)`, []any{"a", "c"}},
+		{`this.items.groupBy(i, i.kind).size()`, int64(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAggregationsTypes(t *testing.T) {
+	env, err := DefaultEnv.Extend(Aggregations())
+	if !assert.NoError(t, err) {
+		return
+	}
+	tests := []struct {
+		expression string
+		want       *Type
+	}{
+		{`[1, 2].sum()`, IntType},
+		{`[1u, 2u].sum()`, UintType},
+		{`[1.0].sum()`, DoubleType},
+		{`[1, 2].avg()`, DoubleType},
+		{`["a"].min()`, StringType},
+		{`[1, 2].groupBy(x, x % 2 == 0)`, MapType(BoolType, ListType(IntType))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, e.OutputType())
+			}
+		})
+	}
+
+	_, err = NewExpr(`["a"].sum()`, env)
+	assert.Error(t, err)
+}
+
+func TestAggregationsErrors(t *testing.T) {
+	env, err := DefaultEnv.Extend(Aggregations())
+	if !assert.NoError(t, err) {
+		return
+	}
+	input := WrapThisVariable(map[string]any{"max": math.MaxInt64, "items": []any{}})
+	tests := []struct {
+		expression string
+		errMsg     string
+	}{
+		{`[this.max, 1].sum()`, "integer overflow"},
+		{`[].avg()`, "avg: empty list"},
+		{`this.items.min()`, "min: empty list"},
+		{`this.items.maxBy(i, i)`, "maxBy: empty list"},
+		{`[1.5, 2.5].groupBy(x, x)`, "groupBy: unsupported key type 'double'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = e.Eval(input)
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestAggregationsWithStandardExtensions(t *testing.T) {
+	env, err := StandardEnv.Extend(Aggregations())
+	if !assert.NoError(t, err) {
+		return
+	}
+	e, err := NewExpr(`[2, 1, 2].distinct().sortBy(x, -x)`, env)
+	if assert.NoError(t, err) {
+		got, err := e.Eval(map[string]any{})
+		assert.NoError(t, err)
+		assert.Equal(t, []any{int64(2), int64(1)}, got)
+	}
+}
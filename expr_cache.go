@@ -0,0 +1,168 @@
+package expr
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ExprCache 缓存编译后的表达式，相同的 (Env, 表达式) 返回同一个 *Expr。
+// 缓存按 LRU 淘汰，可选设置过期时间，并发安全；同一个 key 的并发编译只会执行一次。
+type ExprCache struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	items    map[cacheKey]*list.Element
+	lru      *list.List
+	inflight map[cacheKey]*cacheCall
+	stats    CacheStats
+}
+
+// CacheStats 缓存的统计信息
+type CacheStats struct {
+	Hits      uint64 // 命中缓存（包括等待其他协程编译完成）的次数
+	Misses    uint64 // 未命中缓存而执行编译的次数
+	Evictions uint64 // 因容量不足或过期被淘汰的次数
+	Size      int    // 当前缓存的表达式数量
+}
+
+// CacheOption ExprCache 的配置项
+type CacheOption func(c *ExprCache)
+
+type cacheKey struct {
+	env        *Env
+	expression string
+}
+
+func newCacheKey(expression string, env []*Env) cacheKey {
+	key := cacheKey{env: DefaultEnv, expression: expression}
+	if len(env) != 0 {
+		key.env = env[0]
+	}
+	return key
+}
+
+type cacheEntry struct {
+	key      cacheKey
+	expr     *Expr
+	expireAt time.Time
+}
+
+type cacheCall struct {
+	wg   sync.WaitGroup
+	expr *Expr
+	err  error
+}
+
+// CacheTTL 设置缓存的过期时间，ttl <= 0 表示永不过期
+func CacheTTL(ttl time.Duration) CacheOption {
+	return func(c *ExprCache) {
+		c.ttl = ttl
+	}
+}
+
+// NewExprCache 创建最多缓存 capacity 个表达式的缓存，capacity <= 0 表示不限制数量
+func NewExprCache(capacity int, opts ...CacheOption) *ExprCache {
+	c := &ExprCache{
+		capacity: capacity,
+		now:      time.Now,
+		items:    make(map[cacheKey]*list.Element),
+		lru:      list.New(),
+		inflight: make(map[cacheKey]*cacheCall),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get 返回缓存中的表达式，未命中时使用 NewExpr 编译并缓存，编译失败的结果不会被缓存
+func (c *ExprCache) Get(expression string, env ...*Env) (*Expr, error) {
+	key := newCacheKey(expression, env)
+
+	c.mu.Lock()
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*cacheEntry)
+		if c.ttl <= 0 || c.now().Before(entry.expireAt) {
+			c.lru.MoveToFront(elem)
+			c.stats.Hits++
+			c.mu.Unlock()
+			return entry.expr, nil
+		}
+		c.removeElement(elem)
+		c.stats.Evictions++
+	}
+	if call, ok := c.inflight[key]; ok {
+		c.stats.Hits++
+		c.mu.Unlock()
+		call.wg.Wait()
+		return call.expr, call.err
+	}
+	call := &cacheCall{}
+	call.wg.Add(1)
+	c.inflight[key] = call
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	call.expr, call.err = NewExpr(expression, key.env)
+	call.wg.Done()
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	if call.err == nil {
+		c.add(key, call.expr)
+	}
+	c.mu.Unlock()
+	return call.expr, call.err
+}
+
+// Remove 从缓存中移除表达式
+func (c *ExprCache) Remove(expression string, env ...*Env) {
+	key := newCacheKey(expression, env)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.removeElement(elem)
+	}
+}
+
+// Purge 清空缓存
+func (c *ExprCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[cacheKey]*list.Element)
+	c.lru.Init()
+}
+
+// Stats 返回缓存的统计信息
+func (c *ExprCache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Size = c.lru.Len()
+	return stats
+}
+
+func (c *ExprCache) add(key cacheKey, expr *Expr) {
+	entry := &cacheEntry{key: key, expr: expr}
+	if c.ttl > 0 {
+		entry.expireAt = c.now().Add(c.ttl)
+	}
+	if elem, ok := c.items[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.lru.PushFront(entry)
+	for c.capacity > 0 && c.lru.Len() > c.capacity {
+		c.removeElement(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *ExprCache) removeElement(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*cacheEntry).key)
+}
@@ -0,0 +1,86 @@
+package expr
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExprCache_Get(t *testing.T) {
+	c := NewExprCache(2)
+
+	e1, err := c.Get("this.value > 1")
+	assert.NoError(t, err)
+	e2, err := c.Get("this.value > 1")
+	assert.NoError(t, err)
+	assert.Same(t, e1, e2)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 1, Size: 1}, c.Stats())
+
+	// 不同的 Env 使用不同的缓存
+	env, err := NewEnv(UseThisVariable())
+	assert.NoError(t, err)
+	e3, err := c.Get("this.value > 1", env)
+	assert.NoError(t, err)
+	assert.NotSame(t, e1, e3)
+
+	// 超出容量时淘汰最久未使用的表达式
+	_, err = c.Get("this.value > 2")
+	assert.NoError(t, err)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 3, Evictions: 1, Size: 2}, c.Stats())
+	e4, err := c.Get("this.value > 1")
+	assert.NoError(t, err)
+	assert.NotSame(t, e1, e4)
+
+	// 编译失败不缓存
+	_, err = c.Get("dummy == 1")
+	assert.Error(t, err)
+	_, err = c.Get("dummy == 1")
+	assert.Error(t, err)
+	assert.Equal(t, uint64(6), c.Stats().Misses)
+
+	c.Remove("this.value > 1")
+	c.Purge()
+	assert.Equal(t, 0, c.Stats().Size)
+}
+
+func TestExprCache_TTL(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewExprCache(0, CacheTTL(time.Minute))
+	c.now = func() time.Time { return now }
+
+	e1, err := c.Get("1 + 2")
+	assert.NoError(t, err)
+	now = now.Add(30 * time.Second)
+	e2, err := c.Get("1 + 2")
+	assert.NoError(t, err)
+	assert.Same(t, e1, e2)
+
+	now = now.Add(time.Minute)
+	e3, err := c.Get("1 + 2")
+	assert.NoError(t, err)
+	assert.NotSame(t, e1, e3)
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Evictions: 1, Size: 1}, c.Stats())
+}
+
+func TestExprCache_Concurrent(t *testing.T) {
+	c := NewExprCache(10)
+	var wg sync.WaitGroup
+	exprs := make([]*Expr, 50)
+	for i := range exprs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			e, err := c.Get("this.items.filter(x, x > 1).size() > 0")
+			assert.NoError(t, err)
+			exprs[i] = e
+		}(i)
+	}
+	wg.Wait()
+	for _, e := range exprs {
+		assert.Same(t, exprs[0], e)
+	}
+	assert.Equal(t, uint64(1), c.Stats().Misses)
+	assert.Equal(t, uint64(49), c.Stats().Hits)
+}
@@ -21,11 +21,18 @@ var (
 
 type Type = cel.Type
 type Option = cel.EnvOption
+type Ast = cel.Ast
 
 func Types(addTypes ...any) Option {
 	return cel.Types(addTypes...)
 }
 
+// TypeDescs 注册 protobuf 描述符中声明的类型，支持 FileDescriptorSet、FileDescriptorProto、
+// protoreflect.FileDescriptor 和 protoregistry.Files，适用于运行时才加载的类型
+func TypeDescs(descs ...any) Option {
+	return cel.TypeDescs(descs...)
+}
+
 func Variable(name string, t *Type) Option {
 	return cel.Variable(name, t)
 }
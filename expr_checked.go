@@ -0,0 +1,197 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	celpb "cel.dev/expr"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"google.golang.org/protobuf/proto"
+)
+
+// ErrEnvIncompatible 加载编译结果时，环境缺少表达式引用的变量、函数或类型，或者变量的类型不一致
+var ErrEnvIncompatible = errors.New("env is incompatible with the checked expression")
+
+// CheckedExpr 返回表达式经类型检查后的语法树的 protobuf 表示
+func (e *Expr) CheckedExpr() (*celpb.CheckedExpr, error) {
+	checked, err := cel.AstToCheckedExpr(e.ast)
+	if err != nil {
+		return nil, err
+	}
+	// cel.dev/expr 与 cel-go 使用的 v1alpha1 消息的编码一致，通过序列化完成转换
+	data, err := proto.Marshal(checked)
+	if err != nil {
+		return nil, err
+	}
+	out := &celpb.CheckedExpr{}
+	if err := proto.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MarshalBinary 将表达式的编译结果序列化为 CheckedExpr 的 protobuf 编码，可通过 UnmarshalExpr 加载
+func (e *Expr) MarshalBinary() ([]byte, error) {
+	checked, err := cel.AstToCheckedExpr(e.ast)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(checked)
+}
+
+// UnmarshalExpr 从 MarshalBinary 的结果加载表达式，不再解析和类型检查，
+// env 缺少表达式引用的变量、函数或类型时返回 ErrEnvIncompatible
+func UnmarshalExpr(data []byte, env ...*Env) (*Expr, error) {
+	checked := &exprpb.CheckedExpr{}
+	if err := proto.Unmarshal(data, checked); err != nil {
+		return nil, fmt.Errorf("unmarshal checked expression: %w", err)
+	}
+	return newExprFromChecked(checked, env)
+}
+
+// NewExprFromChecked 从 CheckedExpr 创建表达式，不再解析和类型检查，
+// env 缺少表达式引用的变量、函数或类型时返回 ErrEnvIncompatible
+func NewExprFromChecked(checked *celpb.CheckedExpr, env ...*Env) (*Expr, error) {
+	data, err := proto.Marshal(checked)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalExpr(data, env...)
+}
+
+func newExprFromChecked(checked *exprpb.CheckedExpr, env []*Env) (*Expr, error) {
+	_env, err := selectEnv(env)
+	if err != nil {
+		return nil, err
+	}
+	ast, err := cel.CheckedExprToAstWithSource(checked, nil)
+	if err != nil {
+		return nil, fmt.Errorf("load checked expression: %w", err)
+	}
+	if err := _env.checkCompatible(ast); err != nil {
+		return nil, err
+	}
+	return newExpr(_env, ast)
+}
+
+// checkCompatible 检查语法树引用的变量、函数重载和类型在环境中都有相同的声明
+func (e *Env) checkCompatible(ast *Ast) error {
+	native := ast.NativeRep()
+	overloads := make(map[string]struct{})
+	for _, fn := range e.env.Functions() {
+		for _, o := range fn.OverloadDecls() {
+			overloads[o.ID()] = struct{}{}
+		}
+	}
+	refs := native.ReferenceMap()
+	locals := make(map[int64]struct{})
+	collectLocalIdents(native.Expr(), nil, locals)
+	for _, id := range sortedIDs(refs) {
+		ref := refs[id]
+		for _, o := range ref.OverloadIDs {
+			if _, ok := overloads[o]; !ok {
+				return fmt.Errorf("%w: function overload '%s' is not declared", ErrEnvIncompatible, o)
+			}
+		}
+		if ref.Name == "" || ref.Value != nil || len(ref.OverloadIDs) > 0 {
+			continue
+		}
+		if _, ok := locals[id]; ok {
+			// 推导式和 cel.bind 的局部变量不需要在环境中声明
+			continue
+		}
+		want := native.GetType(id)
+		if want.Kind() == types.StructKind && want.TypeName() == ref.Name {
+			// 构造 protobuf 消息时引用的是消息类型，由下方的类型检查处理
+			continue
+		}
+		identAst, issues := e.env.Compile(ref.Name)
+		if issues.Err() != nil {
+			return fmt.Errorf("%w: variable '%s' is not declared", ErrEnvIncompatible, ref.Name)
+		}
+		if got := identAst.OutputType(); !got.IsExactType(want) {
+			return fmt.Errorf("%w: variable '%s' is declared as '%s', want '%s'", ErrEnvIncompatible, ref.Name, got, want)
+		}
+	}
+	provider := e.env.CELTypeProvider()
+	typeMap := native.TypeMap()
+	for _, id := range sortedIDs(typeMap) {
+		t := typeMap[id]
+		if t.Kind() != types.StructKind {
+			continue
+		}
+		if _, ok := provider.FindStructType(t.TypeName()); !ok {
+			return fmt.Errorf("%w: type '%s' is not declared", ErrEnvIncompatible, t.TypeName())
+		}
+	}
+	return nil
+}
+
+// collectLocalIdents 收集引用推导式局部变量（遍历变量和累加变量）的标识符节点，sc 为当前作用域中的局部变量
+func collectLocalIdents(e ast.Expr, sc map[string]struct{}, locals map[int64]struct{}) {
+	switch e.Kind() {
+	case ast.IdentKind:
+		if _, ok := sc[e.AsIdent()]; ok {
+			locals[e.ID()] = struct{}{}
+		}
+	case ast.SelectKind:
+		collectLocalIdents(e.AsSelect().Operand(), sc, locals)
+	case ast.CallKind:
+		call := e.AsCall()
+		if call.IsMemberFunction() {
+			collectLocalIdents(call.Target(), sc, locals)
+		}
+		for _, arg := range call.Args() {
+			collectLocalIdents(arg, sc, locals)
+		}
+	case ast.ComprehensionKind:
+		comp := e.AsComprehension()
+		collectLocalIdents(comp.IterRange(), sc, locals)
+		collectLocalIdents(comp.AccuInit(), sc, locals)
+		loop := withLocals(sc, comp.IterVar(), comp.IterVar2(), comp.AccuVar())
+		collectLocalIdents(comp.LoopCondition(), loop, locals)
+		collectLocalIdents(comp.LoopStep(), loop, locals)
+		collectLocalIdents(comp.Result(), withLocals(sc, comp.AccuVar()), locals)
+	case ast.ListKind:
+		for _, elem := range e.AsList().Elements() {
+			collectLocalIdents(elem, sc, locals)
+		}
+	case ast.MapKind:
+		for _, entry := range e.AsMap().Entries() {
+			collectLocalIdents(entry.AsMapEntry().Key(), sc, locals)
+			collectLocalIdents(entry.AsMapEntry().Value(), sc, locals)
+		}
+	case ast.StructKind:
+		for _, field := range e.AsStruct().Fields() {
+			collectLocalIdents(field.AsStructField().Value(), sc, locals)
+		}
+	}
+}
+
+// withLocals 返回在 sc 的基础上增加局部变量 names 的新作用域，忽略空的名称
+func withLocals(sc map[string]struct{}, names ...string) map[string]struct{} {
+	out := make(map[string]struct{}, len(sc)+len(names))
+	for k := range sc {
+		out[k] = struct{}{}
+	}
+	for _, name := range names {
+		if name != "" {
+			out[name] = struct{}{}
+		}
+	}
+	return out
+}
+
+// sortedIDs 返回按节点顺序排列的 id，保证检查的顺序和错误信息稳定
+func sortedIDs[V any](m map[int64]V) []int64 {
+	ids := make([]int64, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
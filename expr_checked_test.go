@@ -0,0 +1,154 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zhijingtech/expr/testdata"
+)
+
+func TestExpr_MarshalBinary(t *testing.T) {
+	env, err := NewEnv(
+		Types(&testdata.Rectangle{}),
+		Variable("this", ObjectType("testdata.Rectangle")),
+		Function("twice", Overload("twice_double", []*Type{DoubleType}, DoubleType, UnaryBinding(func(arg Val) Val {
+			return arg.(Double) * 2
+		}))),
+	)
+	assert.NoError(t, err)
+	e, err := NewExpr("twice(this.P2.X - this.P1.X) > 3.0 && testdata.Point{X: 1.0}.X == 1.0", env)
+	assert.NoError(t, err)
+
+	data, err := e.MarshalBinary()
+	assert.NoError(t, err)
+
+	loaded, err := UnmarshalExpr(data, env)
+	assert.NoError(t, err)
+	assert.Equal(t, BoolType, loaded.OutputType())
+	input := map[string]any{"this": &testdata.Rectangle{
+		P1: &testdata.Point{X: 1, Y: 2},
+		P2: &testdata.Point{X: 3, Y: 4},
+	}}
+	got, err := loaded.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, true, got)
+
+	checked, err := e.CheckedExpr()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, checked.GetReferenceMap())
+	loaded, err = NewExprFromChecked(checked, env)
+	assert.NoError(t, err)
+	got, err = loaded.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, true, got)
+}
+
+func TestUnmarshalExpr_Err(t *testing.T) {
+	env, err := NewEnv(
+		Types(&testdata.Rectangle{}),
+		Variable("this", ObjectType("testdata.Rectangle")),
+		Function("twice", Overload("twice_double", []*Type{DoubleType}, DoubleType, UnaryBinding(func(arg Val) Val {
+			return arg.(Double) * 2
+		}))),
+	)
+	assert.NoError(t, err)
+
+	e, err := NewExpr("twice(this.P1.X) > 1.0", env)
+	assert.NoError(t, err)
+	data, err := e.MarshalBinary()
+	assert.NoError(t, err)
+
+	_, err = UnmarshalExpr(data)
+	assert.ErrorIs(t, err, ErrEnvIncompatible)
+	assert.EqualError(t, err, "env is incompatible with the checked expression: function overload 'twice_double' is not declared")
+
+	other, err := NewEnv(
+		Types(&testdata.Rectangle{}),
+		Variable("this", ObjectType("testdata.Point")),
+		Function("twice", Overload("twice_double", []*Type{DoubleType}, DoubleType, UnaryBinding(func(arg Val) Val {
+			return arg
+		}))),
+	)
+	assert.NoError(t, err)
+	_, err = UnmarshalExpr(data, other)
+	assert.EqualError(t, err, "env is incompatible with the checked expression: variable 'this' is declared as 'testdata.Point', want 'testdata.Rectangle'")
+
+	e, err = NewExpr("testdata.Point{X: 1.0}.X")
+	assert.Nil(t, e)
+	assert.Error(t, err)
+	e, err = NewExpr("testdata.Point{X: 1.0}.X", env)
+	assert.NoError(t, err)
+	data, err = e.MarshalBinary()
+	assert.NoError(t, err)
+	_, err = UnmarshalExpr(data)
+	assert.ErrorIs(t, err, ErrEnvIncompatible)
+
+	_, err = UnmarshalExpr([]byte("bad"), env)
+	assert.Error(t, err)
+}
+
+func TestExpr_MarshalBinary_Macros(t *testing.T) {
+	env, err := NewEnv(UseThisVariable(), BindingExtensions(), Aggregations())
+	assert.NoError(t, err)
+	input := WrapThisVariable(map[string]any{"items": []any{3, 1, 2}, "m": map[string]any{"a": 1}})
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{expression: `this.items.all(x, x > 0)`, want: true},
+		{expression: `this.items.exists(x, x > 2) && this.items.exists_one(x, x == 1)`, want: true},
+		{expression: `this.items.map(x, x * 2).filter(y, y > 2).size()`, want: int64(2)},
+		{expression: `this.items.all(x, this.items.exists(y, y <= x))`, want: true},
+		{expression: `this.m.all(k, k == "a")`, want: true},
+		{expression: `cel.bind(n, this.items.size(), n * 2)`, want: int64(6)},
+		{expression: `this.items.sumBy(x, x * 2)`, want: int64(12)},
+		{expression: `this.items.sortBy(x, -x)[0]`, want: int64(3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			data, err := e.MarshalBinary()
+			assert.NoError(t, err)
+			loaded, err := UnmarshalExpr(data, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := loaded.Eval(input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// 部分求值得到的剩余表达式同样可以序列化后加载
+	e, err := NewExpr(`this.limit > 0 && this.orders.all(o, o.amount < this.limit)`, env)
+	assert.NoError(t, err)
+	partial, err := e.PartialEval(WrapThisVariable(map[string]any{"limit": 100}), "this.orders")
+	assert.NoError(t, err)
+	if !assert.False(t, partial.Known()) {
+		return
+	}
+	data, err := partial.Residual.MarshalBinary()
+	assert.NoError(t, err)
+	loaded, err := UnmarshalExpr(data, env)
+	if !assert.NoError(t, err) {
+		return
+	}
+	got, err := loaded.Eval(WrapThisVariable(map[string]any{"limit": 100, "orders": []any{map[string]any{"amount": 50}}}))
+	assert.NoError(t, err)
+	assert.Equal(t, true, got)
+
+	// 局部变量与环境中的变量同名时，推导式外的引用仍需检查
+	e, err = NewExpr(`this.items.all(this, this > 0) && this.items.size() > 0`, env)
+	assert.NoError(t, err)
+	data, err = e.MarshalBinary()
+	assert.NoError(t, err)
+	other, err := NewEnv(Variable("this", StringType))
+	assert.NoError(t, err)
+	_, err = UnmarshalExpr(data, other)
+	assert.ErrorIs(t, err, ErrEnvIncompatible)
+	assert.Contains(t, err.Error(), "variable 'this' is declared as 'string'")
+}
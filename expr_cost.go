@@ -0,0 +1,136 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker"
+	"github.com/google/cel-go/interpreter"
+)
+
+// ErrCostLimitExceeded 表达式的静态估算代价或实际执行代价超出限制，
+// 可通过 errors.As 获取 *EstimatedCostError 或 *CostLimitError 查看具体的代价
+var ErrCostLimitExceeded = errors.New("cost limit exceeded")
+
+// CostEstimate 表达式代价的估算范围
+type CostEstimate = checker.CostEstimate
+
+// SizeHints 估算代价时使用的 list、map、string 和 bytes 的最大长度，key 为字段路径，
+// 如 this.items；list 的元素和 map 的值记为 [*]，如 this.items[*].tags；"*" 为未声明字段的默认长度。
+// 没有提示的字段按无限长估算。
+type SizeHints map[string]uint64
+
+// EstimatedCostError 编译时静态估算的最大代价超出限制
+type EstimatedCostError struct {
+	Limit    uint64
+	Estimate CostEstimate
+}
+
+func (e *EstimatedCostError) Error() string {
+	return fmt.Sprintf("estimated cost %d exceeds limit %d", e.Estimate.Max, e.Limit)
+}
+
+func (e *EstimatedCostError) Is(target error) bool {
+	return target == ErrCostLimitExceeded
+}
+
+// CostLimitError 执行代价超出限制，Actual 为中止时已消耗的代价
+type CostLimitError struct {
+	Limit  uint64
+	Actual uint64
+}
+
+func (e *CostLimitError) Error() string {
+	return fmt.Sprintf("actual cost %d exceeds limit %d", e.Actual, e.Limit)
+}
+
+func (e *CostLimitError) Is(target error) bool {
+	return target == ErrCostLimitExceeded
+}
+
+// WithCostLimit 返回限制执行代价的新环境，执行代价超出 limit 时中止并返回 *CostLimitError，limit 为 0 时不限制
+func (e *Env) WithCostLimit(limit uint64) *Env {
+	ext := e.clone()
+	ext.costLimit = limit
+	return ext
+}
+
+// WithMaxEstimatedCost 返回在编译时估算表达式最大代价的新环境，
+// 估算的最大代价超出 limit 的表达式编译失败，返回的 *CompileError 包装了 *EstimatedCostError，limit 为 0 时不估算
+func (e *Env) WithMaxEstimatedCost(limit uint64, hints SizeHints) *Env {
+	ext := e.clone()
+	ext.maxEstimatedCost = limit
+	ext.sizeHints = hints
+	return ext
+}
+
+// EstimateCost 使用 hints 估算表达式的执行代价
+func (e *Expr) EstimateCost(hints SizeHints) (CostEstimate, error) {
+	return e.env.env.EstimateCost(e.ast, sizeHintEstimator(hints))
+}
+
+func (e *Env) checkEstimatedCost(ast *cel.Ast) error {
+	est, err := e.env.EstimateCost(ast, sizeHintEstimator(e.sizeHints))
+	if err != nil {
+		return err
+	}
+	if est.Max > e.maxEstimatedCost {
+		return &EstimatedCostError{Limit: e.maxEstimatedCost, Estimate: est}
+	}
+	return nil
+}
+
+// costLimitErr 将执行代价超出限制的中止错误转换为 *CostLimitError
+func (e *Expr) costLimitErr(err error, det *cel.EvalDetails) (*CostLimitError, bool) {
+	var cancelled interpreter.EvalCancelledError
+	if !errors.As(err, &cancelled) || cancelled.Cause != interpreter.CostLimitExceeded {
+		return nil, false
+	}
+	costErr := &CostLimitError{Limit: e.env.costLimit}
+	if det != nil && det.ActualCost() != nil {
+		costErr.Actual = *det.ActualCost()
+	}
+	return costErr, true
+}
+
+type sizeHintEstimator SizeHints
+
+func (h sizeHintEstimator) EstimateSize(element checker.AstNode) *checker.SizeEstimate {
+	if len(h) == 0 {
+		return nil
+	}
+	if path := element.Path(); len(path) > 0 {
+		if size, ok := h[sizeHintPath(path)]; ok {
+			return &checker.SizeEstimate{Min: 0, Max: size}
+		}
+	}
+	if size, ok := h["*"]; ok {
+		return &checker.SizeEstimate{Min: 0, Max: size}
+	}
+	return nil
+}
+
+func (h sizeHintEstimator) EstimateCallCost(function, overloadID string, target *checker.AstNode, args []checker.AstNode) *checker.CallEstimate {
+	return nil
+}
+
+// sizeHintPath 将代价估算使用的路径转换为 SizeHints 的 key
+func sizeHintPath(path []string) string {
+	var b strings.Builder
+	for i, p := range path {
+		switch {
+		case p == "@items" || p == "@values":
+			b.WriteString("[*]")
+		case p == "@keys":
+			b.WriteString("[key]")
+		case i > 0:
+			b.WriteString(".")
+			b.WriteString(p)
+		default:
+			b.WriteString(p)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,81 @@
+package expr
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpr_EstimateCost(t *testing.T) {
+	e, err := NewExpr(`this.items.map(x, this.items.filter(y, y == x)).size() > 0`)
+	assert.NoError(t, err)
+
+	small, err := e.EstimateCost(SizeHints{"this.items": 10, "*": 1})
+	assert.NoError(t, err)
+	large, err := e.EstimateCost(SizeHints{"this.items": 1000, "*": 1})
+	assert.NoError(t, err)
+	assert.Greater(t, large.Max, small.Max)
+
+	// 默认长度
+	def, err := e.EstimateCost(SizeHints{"*": 10})
+	assert.NoError(t, err)
+	explicit, err := e.EstimateCost(SizeHints{"this.items": 10, "*": 10})
+	assert.NoError(t, err)
+	assert.Equal(t, explicit.Max, def.Max)
+
+	// 元素为 dyn 类型时比较的代价与元素长度相关，没有默认长度时按无限长估算
+	unbounded, err := e.EstimateCost(SizeHints{"this.items": 10})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), unbounded.Max)
+
+	assert.Equal(t, "this.items[*].tags", sizeHintPath([]string{"this", "items", "@items", "tags"}))
+}
+
+func TestEnv_WithMaxEstimatedCost(t *testing.T) {
+	env := DefaultEnv.WithMaxEstimatedCost(10000, SizeHints{"this.items": 10})
+
+	_, err := NewExpr(`this.items.exists(x, x > 1)`, env)
+	assert.NoError(t, err)
+
+	_, err = NewExpr(`this.items.map(x, this.items.map(y, this.items.filter(z, x + y == z))).size() > 0`, env)
+	assert.ErrorIs(t, err, ErrCostLimitExceeded)
+	var estErr *EstimatedCostError
+	assert.True(t, errors.As(err, &estErr))
+	assert.Equal(t, uint64(10000), estErr.Limit)
+	assert.Greater(t, estErr.Estimate.Max, uint64(10000))
+
+	// 没有长度提示时按无限长估算
+	_, err = NewExpr(`this.other.exists(x, x > 1)`, env)
+	assert.ErrorIs(t, err, ErrCostLimitExceeded)
+}
+
+func TestEnv_WithCostLimit(t *testing.T) {
+	env := DefaultEnv.WithCostLimit(1000)
+	e, err := NewExpr(`this.items.map(x, this.items.filter(y, y == x)).size()`, env)
+	assert.NoError(t, err)
+
+	items := make([]any, 5)
+	for i := range items {
+		items[i] = i
+	}
+	v, err := e.Eval(WrapThisVariable(map[string]any{"items": items}))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), v)
+
+	items = make([]any, 100)
+	for i := range items {
+		items[i] = i
+	}
+	_, err = e.Eval(WrapThisVariable(map[string]any{"items": items}))
+	assert.ErrorIs(t, err, ErrCostLimitExceeded)
+	var costErr *CostLimitError
+	assert.True(t, errors.As(err, &costErr))
+	assert.Equal(t, uint64(1000), costErr.Limit)
+	assert.Greater(t, costErr.Actual, uint64(1000))
+
+	_, err = e.EvalContext(context.Background(), WrapThisVariable(map[string]any{"items": items}))
+	assert.ErrorIs(t, err, ErrCostLimitExceeded)
+}
@@ -0,0 +1,55 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Decoder 将表达式执行得到的 cel 值解析为调用方需要的 Go 值
+type Decoder interface {
+	Decode(v Val) (any, error)
+}
+
+// DecoderFunc 将普通函数适配为 Decoder
+type DecoderFunc func(v Val) (any, error)
+
+func (f DecoderFunc) Decode(v Val) (any, error) {
+	return f(v)
+}
+
+var (
+	// DefaultDecoder 默认的解析器，返回 cel 值对应的 Go 值，顶层的 map 和 list 分别转换为 map[any]any 和 []any
+	DefaultDecoder Decoder = DecoderFunc(decodeDefault)
+
+	toSliceAny  = reflect.TypeOf([]any{})
+	toMapAnyAny = reflect.TypeOf(map[any]any{})
+)
+
+func decodeDefault(ev Val) (any, error) {
+	v := ev.Value()
+	switch v.(type) {
+	case map[Val]Val:
+		tmp, err := ev.ConvertToNative(toMapAnyAny)
+		if err == nil {
+			v = tmp
+		}
+	case []Val:
+		tmp, err := ev.ConvertToNative(toSliceAny)
+		if err == nil {
+			v = tmp
+		}
+	}
+	return v, nil
+}
+
+// NativeDecoder 返回将执行结果转换为 typ 类型的解析器，支持 cel 值所能转换的全部 Go 类型，
+// 如 map[string]any、[]string、protobuf 消息的指针类型等
+func NativeDecoder(typ reflect.Type) Decoder {
+	return DecoderFunc(func(ev Val) (any, error) {
+		v, err := ev.ConvertToNative(typ)
+		if err != nil {
+			return nil, fmt.Errorf("decode %s to %v: %w", ev.Type(), typ, err)
+		}
+		return v, nil
+	})
+}
@@ -0,0 +1,77 @@
+package expr
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zhijingtech/expr/testdata"
+)
+
+func TestExpr_EvalDecoder(t *testing.T) {
+	input := map[string]any{"this": map[string]any{"a": 1, "b": "x"}}
+
+	e, err := NewExpr(`{"a": this.a, "b": this.b}`)
+	assert.NoError(t, err)
+	got, err := e.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, map[any]any{"a": int64(1), "b": "x"}, got)
+
+	got, err = e.WithDecoder(NativeDecoder(reflect.TypeOf(map[string]any{}))).Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]any{"a": int64(1), "b": "x"}, got)
+
+	_, err = e.WithDecoder(NativeDecoder(reflect.TypeOf(""))).Eval(input)
+	assert.Error(t, err)
+
+	// 原表达式不受影响
+	got, err = e.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, map[any]any{"a": int64(1), "b": "x"}, got)
+}
+
+func TestEnv_WithDecoder(t *testing.T) {
+	env, err := NewEnv(UseThisVariable())
+	assert.NoError(t, err)
+	env = env.WithDecoder(DecoderFunc(func(v Val) (any, error) {
+		if v.Type() != BoolType {
+			return nil, errors.New("not a bool")
+		}
+		if v == Bool(true) {
+			return "yes", nil
+		}
+		return "no", nil
+	}))
+
+	e, err := NewExpr("this.value > 60", env)
+	assert.NoError(t, err)
+	got, err := e.Eval(map[string]any{"this": map[string]any{"value": 80}})
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", got)
+
+	e, err = NewExpr("this.value", env)
+	assert.NoError(t, err)
+	got, err = e.Eval(map[string]any{"this": map[string]any{"value": 80}})
+	assert.EqualError(t, err, "not a bool")
+	assert.Nil(t, got)
+
+	// Extend 保留解析器
+	env, err = env.Extend(Types(&testdata.Rectangle{}), Variable("rect", ObjectType("testdata.Rectangle")))
+	assert.NoError(t, err)
+	e, err = NewExpr("rect.P1.X < rect.P2.X", env)
+	assert.NoError(t, err)
+	got, err = e.Eval(map[string]any{"rect": &testdata.Rectangle{
+		P1: &testdata.Point{X: 1, Y: 2},
+		P2: &testdata.Point{X: 3, Y: 4},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", got)
+
+	e, err = NewExpr(`testdata.Point{X: 1.0}`, env)
+	assert.NoError(t, err)
+	got, err = e.WithDecoder(NativeDecoder(reflect.TypeOf(&testdata.Point{}))).Eval(map[string]any{})
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), got.(*testdata.Point).X)
+}
@@ -0,0 +1,290 @@
+package expr
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/parser"
+)
+
+// IssueCode 编译问题的错误码，取值稳定，可用于本地化提示
+type IssueCode string
+
+const (
+	CodeSyntaxError          IssueCode = "syntax_error"
+	CodeUndeclaredReference  IssueCode = "undeclared_reference"
+	CodeNoMatchingOverload   IssueCode = "no_matching_overload"
+	CodeUndefinedField       IssueCode = "undefined_field"
+	CodeUnsupportedSelection IssueCode = "unsupported_selection"
+	CodeTypeMismatch         IssueCode = "type_mismatch"
+	CodeInvalidType          IssueCode = "invalid_type"
+	CodeInvalidComprehension IssueCode = "invalid_comprehension"
+	CodeInvalidRegex         IssueCode = "invalid_regex"
+	CodeInvalidLiteral       IssueCode = "invalid_literal"
+	CodeCostLimitExceeded    IssueCode = "cost_limit_exceeded"
+	CodeUnknown              IssueCode = "unknown"
+)
+
+// Severity 编译问题的严重程度
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Issue 表达式编译时发现的单个问题，Line 从 1 开始，Column 和 Offset 从 0 开始并按字符计算，位置未知时均为 -1
+type Issue struct {
+	Code     IssueCode
+	Severity Severity
+	Message  string
+	// Args 消息中以单引号括起的参数，如未声明的标识符、函数名和类型，便于生成本地化的提示
+	Args    []string
+	Line    int
+	Column  int
+	Offset  int
+	Snippet string
+}
+
+// CompileError 表达式编译失败的错误，Error() 与 cel-go 的错误信息保持一致。
+// 代价估算、创建执行计划等无法定位到源码的错误同样包装为 CompileError，可通过 errors.Is、errors.As 获取原错误
+type CompileError struct {
+	Source string
+	Issues []*Issue
+	msg    string
+	err    error
+}
+
+func (e *CompileError) Error() string {
+	return e.msg
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.err
+}
+
+// issueCodes 按类型检查错误的消息模板识别错误码，每个模板对应 cel-go checker/errors.go 中的一类错误，
+// 以及本包的校验器报告的错误，未匹配的消息为 CodeUnknown
+var issueCodes = []struct {
+	pattern *regexp.Regexp
+	code    IssueCode
+}{
+	{regexp.MustCompile(`(?s)^undeclared reference to '.*' \(in container '.*'\)$`), CodeUndeclaredReference},
+	{regexp.MustCompile(`(?s)^found no matching overload for '.*' applied to '.*'$`), CodeNoMatchingOverload},
+	{regexp.MustCompile(`(?s)^undefined field '.*'$`), CodeUndefinedField},
+	{regexp.MustCompile(`(?s)^unsupported optional field selection: .*$`), CodeUnsupportedSelection},
+	{regexp.MustCompile(`(?s)^type '.*' does not support field selection$`), CodeUnsupportedSelection},
+	{regexp.MustCompile(`(?s)^expected type of field '.*' is '.*' but provided type is '.*'$`), CodeTypeMismatch},
+	{regexp.MustCompile(`(?s)^expected type '.*' but found '.*'$`), CodeTypeMismatch},
+	{regexp.MustCompile(`(?s)^expression of type '.*' cannot be range of a comprehension \(must be list, map, or dynamic\)$`), CodeInvalidComprehension},
+	{regexp.MustCompile(`(?s)^'.*' is not a (message )?type$`), CodeInvalidType},
+	{regexp.MustCompile(`(?s)^invalid regex pattern '.*': .*$`), CodeInvalidRegex},
+	{regexp.MustCompile(`(?s)^invalid literal '.*' for [\w.]+\(\): .*$`), CodeInvalidLiteral},
+}
+
+var quotedArgPattern = regexp.MustCompile(`'([^']*)'`)
+
+// newCompileError 将 cel-go 的编译问题转换为 *CompileError，syntax 表示问题发生在语法解析阶段
+func newCompileError(source common.Source, issues *cel.Issues, syntax bool) *CompileError {
+	err := &CompileError{Source: source.Content(), msg: issues.String()}
+	for _, e := range issues.Errors() {
+		issue := &Issue{
+			Code:     issueCode(e.Message, syntax),
+			Severity: SeverityError,
+			Message:  e.Message,
+			Line:     -1,
+			Column:   -1,
+			Offset:   -1,
+		}
+		for _, m := range quotedArgPattern.FindAllStringSubmatch(e.Message, -1) {
+			issue.Args = append(issue.Args, m[1])
+		}
+		if loc := e.Location; loc != nil && loc.Line() > 0 {
+			issue.Line, issue.Column = loc.Line(), loc.Column()
+			if offset, ok := source.LocationOffset(loc); ok {
+				issue.Offset = int(offset)
+			}
+			issue.Snippet, _ = source.Snippet(loc.Line())
+		}
+		err.Issues = append(err.Issues, issue)
+	}
+	return err
+}
+
+func issueCode(message string, syntax bool) IssueCode {
+	if syntax {
+		return CodeSyntaxError
+	}
+	for _, c := range issueCodes {
+		if c.pattern.MatchString(message) {
+			return c.code
+		}
+	}
+	return CodeUnknown
+}
+
+// wrapCompileError 将无法定位到源码的编译期错误包装为 *CompileError，已经是 *CompileError 的错误原样返回
+func wrapCompileError(source string, code IssueCode, err error) error {
+	if _, ok := err.(*CompileError); ok {
+		return err
+	}
+	issue := &Issue{Code: code, Severity: SeverityError, Message: err.Error(), Line: -1, Column: -1, Offset: -1}
+	for _, m := range quotedArgPattern.FindAllStringSubmatch(issue.Message, -1) {
+		issue.Args = append(issue.Args, m[1])
+	}
+	return &CompileError{Source: source, Issues: []*Issue{issue}, msg: err.Error(), err: err}
+}
+
+// ErrorKind 表达式执行错误的分类
+type ErrorKind string
+
+const (
+	KindUnknown            ErrorKind = "unknown"
+	KindMissingKey         ErrorKind = "missing_key"
+	KindMissingAttribute   ErrorKind = "missing_attribute"
+	KindNoMatchingOverload ErrorKind = "no_matching_overload"
+	KindDivisionByZero     ErrorKind = "division_by_zero"
+	KindFunction           ErrorKind = "function"
+	KindPanic              ErrorKind = "panic"
+	KindCancelled          ErrorKind = "cancelled"
+	KindCostExceeded       ErrorKind = "cost_exceeded"
+)
+
+// 执行错误的哨兵错误，可通过 errors.Is 判断 *EvalError 的分类，
+// 中断和代价超限分别对应 ErrEvalInterrupted 和 ErrCostLimitExceeded
+var (
+	ErrNoSuchKey          = errors.New("no such key")
+	ErrNoSuchAttribute    = errors.New("no such attribute")
+	ErrNoMatchingOverload = errors.New("no matching overload")
+	ErrDivisionByZero     = errors.New("division by zero")
+	ErrFunction           = errors.New("function error")
+	ErrPanic              = errors.New("panic during evaluation")
+)
+
+var kindSentinels = map[ErrorKind]error{
+	KindMissingKey:         ErrNoSuchKey,
+	KindMissingAttribute:   ErrNoSuchAttribute,
+	KindNoMatchingOverload: ErrNoMatchingOverload,
+	KindDivisionByZero:     ErrDivisionByZero,
+	KindFunction:           ErrFunction,
+	KindPanic:              ErrPanic,
+	KindCancelled:          ErrEvalInterrupted,
+	KindCostExceeded:       ErrCostLimitExceeded,
+}
+
+// evalErrorKinds 按消息前缀识别执行错误的分类
+var evalErrorKinds = []struct {
+	prefix string
+	kind   ErrorKind
+}{
+	{"no such key", KindMissingKey},
+	{"no such attribute", KindMissingAttribute},
+	{"no such overload", KindNoMatchingOverload},
+	{"no matching overload", KindNoMatchingOverload},
+	{"division by zero", KindDivisionByZero},
+	{"modulus by zero", KindDivisionByZero},
+	{"internal error:", KindPanic},
+}
+
+// EvalError 表达式执行失败的错误，Error() 与原始错误的信息一致，可通过 errors.Unwrap 获取原始错误。
+// Expr 为出错的子表达式，Line 从 1 开始，Column 和 Offset 从 0 开始，无法定位时 Expr 为空、位置均为 -1
+type EvalError struct {
+	Kind   ErrorKind
+	Expr   string
+	Line   int
+	Column int
+	Offset int
+	Err    error
+}
+
+func newEvalError(kind ErrorKind, err error) *EvalError {
+	return &EvalError{Kind: kind, Line: -1, Column: -1, Offset: -1, Err: err}
+}
+
+func (e *EvalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+func (e *EvalError) Is(target error) bool {
+	sentinel, ok := kindSentinels[e.Kind]
+	return ok && sentinel == target
+}
+
+// functionError 标记自定义函数返回的错误
+type functionError struct {
+	err error
+}
+
+func (e *functionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *functionError) Unwrap() error {
+	return e.err
+}
+
+// markFunctionErr 标记自定义函数返回的错误，以便执行出错时区分错误的来源
+func markFunctionErr(v Val) Val {
+	if err, ok := v.(*types.Err); ok {
+		if _, marked := err.Unwrap().(*functionError); !marked {
+			return types.LabelErrNode(err.NodeID(), types.WrapErr(&functionError{err: err.Unwrap()}))
+		}
+	}
+	return v
+}
+
+// evalErr 将 cel-go 返回的执行错误转换为 *EvalError
+func (e *Expr) evalErr(err error, det *cel.EvalDetails) error {
+	if costErr, ok := e.costLimitErr(err, det); ok {
+		return newEvalError(KindCostExceeded, costErr)
+	}
+	evalErr := newEvalError(evalErrorKind(err), err)
+	var celErr *types.Err
+	if errors.As(err, &celErr) && celErr.NodeID() != 0 {
+		native := e.ast.NativeRep()
+		info := native.SourceInfo()
+		if node := findExpr(native.Expr(), celErr.NodeID()); node != nil {
+			evalErr.Expr, _ = parser.Unparse(node, info)
+			if start, _, ok := offsetRange(node, info); ok {
+				loc := info.GetLocationByOffset(start)
+				evalErr.Offset, evalErr.Line, evalErr.Column = int(start), loc.Line(), loc.Column()
+			}
+		}
+	}
+	return evalErr
+}
+
+// evalErrorKind 返回错误的分类，自定义函数返回的错误先于消息前缀判断，即使消息与内置错误相同也归为 KindFunction
+func evalErrorKind(err error) ErrorKind {
+	var fnErr *functionError
+	if errors.As(err, &fnErr) {
+		return KindFunction
+	}
+	msg := err.Error()
+	for _, k := range evalErrorKinds {
+		if strings.HasPrefix(msg, k.prefix) {
+			return k.kind
+		}
+	}
+	return KindUnknown
+}
+
+// findExpr 查找语法树中指定 ID 的节点
+func findExpr(root ast.Expr, id int64) ast.Expr {
+	var found ast.Expr
+	ast.PreOrderVisit(root, ast.NewExprVisitor(func(e ast.Expr) {
+		if found == nil && e.ID() == id {
+			found = e
+		}
+	}))
+	return found
+}
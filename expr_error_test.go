@@ -0,0 +1,264 @@
+package expr
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileError(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       []*Issue
+	}{
+		{
+			name:       "syntax error",
+			expression: "1 +",
+			want: []*Issue{{
+				Code: CodeSyntaxError, Severity: SeverityError,
+				Message: "Syntax error: mismatched input '<EOF>' expecting {'[', '{', '(', '.', '-', '!', 'true', 'false', 'null', NUM_FLOAT, NUM_INT, NUM_UINT, STRING, BYTES, IDENTIFIER}",
+				Args:    []string{"<EOF>", "[", "{", "(", ".", "-", "!", "true", "false", "null"},
+				Line:    1, Column: 3, Offset: 3, Snippet: "1 +",
+			}},
+		},
+		{
+			name:       "undeclared reference",
+			expression: "1 < 2 &&\n  foo > 1",
+			want: []*Issue{{
+				Code: CodeUndeclaredReference, Severity: SeverityError,
+				Message: "undeclared reference to 'foo' (in container '')",
+				Args:    []string{"foo", ""},
+				Line:    2, Column: 2, Offset: 11, Snippet: "  foo > 1",
+			}},
+		},
+		{
+			name:       "no matching overload",
+			expression: "1.0 < 2",
+			want: []*Issue{{
+				Code: CodeNoMatchingOverload, Severity: SeverityError,
+				Message: "found no matching overload for '_<_' applied to '(double, int)'",
+				Args:    []string{"_<_", "(double, int)"},
+				Line:    1, Column: 4, Offset: 4, Snippet: "1.0 < 2",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewExpr(tt.expression)
+			var compileErr *CompileError
+			assert.True(t, errors.As(err, &compileErr))
+			assert.Equal(t, tt.expression, compileErr.Source)
+			assert.Equal(t, tt.want, compileErr.Issues)
+		})
+	}
+}
+
+func TestCompileError_Multiple(t *testing.T) {
+	env, err := DefaultEnv.Extend(UseThisVariable())
+	assert.NoError(t, err)
+	_, err = NewExpr(`foo && bar(this)`, env)
+	var compileErr *CompileError
+	assert.True(t, errors.As(err, &compileErr))
+	if assert.Len(t, compileErr.Issues, 2) {
+		assert.Equal(t, CodeUndeclaredReference, compileErr.Issues[0].Code)
+		assert.Equal(t, 0, compileErr.Issues[0].Offset)
+		assert.Equal(t, CodeUndeclaredReference, compileErr.Issues[1].Code)
+		assert.Equal(t, []string{"bar", ""}, compileErr.Issues[1].Args)
+	}
+
+	p, err := Parse(`foo && bar(this)`, env)
+	assert.NoError(t, err)
+	_, err = p.Check()
+	assert.True(t, errors.As(err, &compileErr))
+	assert.Len(t, compileErr.Issues, 2)
+
+	_, err = Parse(`foo &&`, env)
+	assert.True(t, errors.As(err, &compileErr))
+	assert.Equal(t, CodeSyntaxError, compileErr.Issues[0].Code)
+}
+
+func TestEvalError(t *testing.T) {
+	env, err := DefaultEnv.Extend(
+		UseThisVariable(),
+		Function("fail", Overload("fail_string", []*Type{StringType}, BoolType,
+			UnaryBinding(func(v Val) Val { return NewErr("failed: %v", v) }))),
+		Function("boom", Overload("boom_int", []*Type{IntType}, BoolType,
+			UnaryBinding(func(v Val) Val { panic("boom") }))),
+		// 错误消息与内置错误的前缀相同
+		Function("lookup", Overload("lookup_string", []*Type{StringType}, BoolType,
+			UnaryBinding(func(v Val) Val { return NewErr("no such key: %v", v) }))),
+		GoFunction("ratio", func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, errors.New("division by zero")
+			}
+			return a / b, nil
+		}),
+	)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		expression string
+		input      map[string]any
+		kind       ErrorKind
+		sentinel   error
+		msg        string
+		expr       string
+		offset     int
+	}{
+		{
+			name:       "missing key",
+			expression: `this.a == 1 && this.m["k"] == 1`,
+			input:      WrapThisVariable(map[string]any{"a": 1, "m": map[string]any{}}),
+			kind:       KindMissingKey,
+			sentinel:   ErrNoSuchKey,
+			msg:        "no such key: k",
+			expr:       `this.m["k"]`,
+			offset:     15,
+		},
+		{
+			name:       "missing attribute",
+			expression: `this.a == 1`,
+			input:      map[string]any{},
+			kind:       KindMissingAttribute,
+			sentinel:   ErrNoSuchAttribute,
+			msg:        "no such attribute(s): this",
+			expr:       "this.a",
+			offset:     0,
+		},
+		{
+			name:       "no matching overload",
+			expression: `this.a + 1 == 2`,
+			input:      WrapThisVariable(map[string]any{"a": "x"}),
+			kind:       KindNoMatchingOverload,
+			sentinel:   ErrNoMatchingOverload,
+			msg:        "no such overload",
+			expr:       `this.a + 1`,
+			offset:     0,
+		},
+		{
+			name:       "division by zero",
+			expression: `1 / this.a == 1`,
+			input:      WrapThisVariable(map[string]any{"a": 0}),
+			kind:       KindDivisionByZero,
+			sentinel:   ErrDivisionByZero,
+			msg:        "division by zero",
+			expr:       `1 / this.a`,
+			offset:     0,
+		},
+		{
+			name:       "function error",
+			expression: `this.a > 0 && fail(this.s)`,
+			input:      WrapThisVariable(map[string]any{"a": 1, "s": "x"}),
+			kind:       KindFunction,
+			sentinel:   ErrFunction,
+			msg:        "failed: x",
+			expr:       `fail(this.s)`,
+			offset:     14,
+		},
+		{
+			name:       "function error like missing key",
+			expression: `lookup(this.s)`,
+			input:      WrapThisVariable(map[string]any{"s": "k"}),
+			kind:       KindFunction,
+			sentinel:   ErrFunction,
+			msg:        "no such key: k",
+			expr:       `lookup(this.s)`,
+			offset:     0,
+		},
+		{
+			name:       "function error like division by zero",
+			expression: `ratio(1, this.a) == 1`,
+			input:      WrapThisVariable(map[string]any{"a": 0}),
+			kind:       KindFunction,
+			sentinel:   ErrFunction,
+			msg:        "division by zero",
+			expr:       `ratio(1, this.a)`,
+			offset:     0,
+		},
+		{
+			name:       "panic",
+			expression: `boom(1)`,
+			input:      map[string]any{},
+			kind:       KindPanic,
+			sentinel:   ErrPanic,
+			msg:        "internal error: boom",
+			offset:     -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			assert.NoError(t, err)
+			_, err = e.Eval(tt.input)
+			assert.EqualError(t, err, tt.msg)
+			assert.ErrorIs(t, err, tt.sentinel)
+			var evalErr *EvalError
+			if assert.True(t, errors.As(err, &evalErr)) {
+				assert.Equal(t, tt.kind, evalErr.Kind)
+				assert.Equal(t, tt.expr, evalErr.Expr)
+				assert.Equal(t, tt.offset, evalErr.Offset)
+			}
+		})
+	}
+}
+
+func TestEvalError_Interrupted(t *testing.T) {
+	e, err := NewExpr(`1 == 1`)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = e.EvalContext(ctx, map[string]any{})
+	assert.ErrorIs(t, err, ErrEvalInterrupted)
+	assert.ErrorIs(t, err, context.Canceled)
+	var evalErr *EvalError
+	assert.True(t, errors.As(err, &evalErr))
+	assert.Equal(t, KindCancelled, evalErr.Kind)
+
+	e, err = NewExpr(`[1, 2, 3].map(x, [1, 2, 3].map(y, x * y)).size() > 0`, DefaultEnv.WithCostLimit(5))
+	assert.NoError(t, err)
+	_, err = e.Eval(map[string]any{})
+	assert.True(t, errors.As(err, &evalErr))
+	assert.Equal(t, KindCostExceeded, evalErr.Kind)
+	var costErr *CostLimitError
+	assert.True(t, errors.As(err, &costErr))
+}
+
+func TestCompileError_Codes(t *testing.T) {
+	tests := []struct {
+		message string
+		want    IssueCode
+	}{
+		{"undeclared reference to 'foo' (in container '')", CodeUndeclaredReference},
+		{"'foo' is not a type", CodeInvalidType},
+		{"something '' went wrong", CodeUnknown},
+		{"unexpected failure", CodeUnknown},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, issueCode(tt.message, false), tt.message)
+	}
+}
+
+func TestCompileError_Wrapped(t *testing.T) {
+	// 不在源码中的错误同样返回 *CompileError，且保留原始错误
+	_, err := NewExpr(`1 == 1`, nil)
+	var compileErr *CompileError
+	if assert.True(t, errors.As(err, &compileErr)) {
+		assert.Equal(t, CodeUnknown, compileErr.Issues[0].Code)
+		assert.Equal(t, -1, compileErr.Issues[0].Offset)
+	}
+	assert.ErrorIs(t, err, ErrEnvNil)
+
+	env := DefaultEnv.WithMaxEstimatedCost(1, nil)
+	_, err = NewExpr(`[1, 2, 3].map(x, x * 2).size() > 0`, env)
+	if assert.True(t, errors.As(err, &compileErr)) {
+		assert.Equal(t, `[1, 2, 3].map(x, x * 2).size() > 0`, compileErr.Source)
+		assert.Equal(t, CodeCostLimitExceeded, compileErr.Issues[0].Code)
+	}
+	assert.ErrorIs(t, err, ErrCostLimitExceeded)
+	var costErr *EstimatedCostError
+	assert.True(t, errors.As(err, &costErr))
+}
@@ -0,0 +1,196 @@
+package expr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+	"github.com/google/cel-go/parser"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxExplainValueLen 子表达式取值的最大展示长度，超出部分截断
+const maxExplainValueLen = 80
+
+// Explanation 表达式的执行过程，记录了每个子表达式的取值
+type Explanation struct {
+	Value any          `json:"value"`
+	Err   error        `json:"-"`
+	Root  *ExplainNode `json:"root"`
+}
+
+// ExplainNode 子表达式及其取值，字面量不单独成为节点
+type ExplainNode struct {
+	Expr     string         `json:"expr"`
+	Value    string         `json:"value"`
+	Offset   int            `json:"offset"` // 子表达式在源码中的起始位置，从 0 开始，不包含括号
+	End      int            `json:"end"`    // 子表达式在源码中的结束位置（不含）
+	Line     int            `json:"line"`
+	Column   int            `json:"column"` // 从 0 开始
+	Children []*ExplainNode `json:"children,omitempty"`
+}
+
+// Explain 执行表达式并记录每个子表达式的取值，用于排查表达式的结果。
+// 为展示完整的取值，逻辑运算不会短路，推导式作为一个整体展示。
+// 表达式执行出错时仍然返回执行过程，错误记录在 Explanation.Err 中。
+func (e *Expr) Explain(input any) (*Explanation, error) {
+	p, err := e.traced.get(e, cel.EvalOptions(cel.OptExhaustiveEval))
+	if err != nil {
+		return nil, err
+	}
+	ev, det, err := p.Eval(input)
+	if det == nil {
+		return nil, err
+	}
+	x := &Explanation{}
+	if err != nil {
+		x.Err = e.evalErr(err, det)
+	}
+	if ev != nil && err == nil {
+		if x.Value, err = e.decode(ev); err != nil {
+			x.Err = err
+		}
+	}
+	native := e.ast.NativeRep()
+	x.Root = explainNode(native.Expr(), native.SourceInfo(), det.State())
+	return x, nil
+}
+
+func explainNode(e ast.Expr, info *ast.SourceInfo, state interpreter.EvalState) *ExplainNode {
+	src, err := parser.Unparse(e, info)
+	if err != nil {
+		src = fmt.Sprintf("<%v>", err)
+	}
+	n := &ExplainNode{Expr: src, Value: "<not evaluated>"}
+	if v, ok := state.Value(e.ID()); ok {
+		n.Value = formatExplainValue(v)
+	}
+	if start, stop, ok := offsetRange(e, info); ok {
+		loc := info.GetLocationByOffset(start)
+		n.Offset, n.End, n.Line, n.Column = int(start), int(stop)+1, loc.Line(), loc.Column()
+	}
+
+	var children []ast.Expr
+	switch e.Kind() {
+	case ast.CallKind:
+		call := e.AsCall()
+		if call.IsMemberFunction() {
+			children = append(children, call.Target())
+		}
+		children = append(children, call.Args()...)
+	case ast.SelectKind:
+		children = append(children, e.AsSelect().Operand())
+	case ast.ListKind:
+		children = append(children, e.AsList().Elements()...)
+	case ast.MapKind:
+		for _, entry := range e.AsMap().Entries() {
+			children = append(children, entry.AsMapEntry().Key(), entry.AsMapEntry().Value())
+		}
+	case ast.StructKind:
+		for _, field := range e.AsStruct().Fields() {
+			children = append(children, field.AsStructField().Value())
+		}
+	}
+	for _, child := range children {
+		if child.Kind() == ast.LiteralKind {
+			continue
+		}
+		// 变量和字段选择组成的路径作为一个整体取值，中间节点没有记录取值
+		if _, ok := state.Value(child.ID()); !ok && (child.Kind() == ast.IdentKind || child.Kind() == ast.SelectKind) {
+			continue
+		}
+		n.Children = append(n.Children, explainNode(child, info, state))
+	}
+	return n
+}
+
+// offsetRange 返回子表达式在源码中的范围，运算符节点的位置是运算符本身，因此取整棵子树的范围
+func offsetRange(e ast.Expr, info *ast.SourceInfo) (start, stop int32, ok bool) {
+	visitor := ast.NewExprVisitor(func(e ast.Expr) {
+		r, found := info.GetOffsetRange(e.ID())
+		if !found {
+			return
+		}
+		if !ok || r.Start < start {
+			start = r.Start
+		}
+		if !ok || r.Stop > stop {
+			stop = r.Stop
+		}
+		ok = true
+	})
+	ast.PostOrderVisit(e, visitor)
+	if call, found := info.GetMacroCall(e.ID()); found {
+		ast.PostOrderVisit(call, visitor)
+	}
+	// 全局函数调用的位置是左括号，起始位置前移到函数名
+	if ok && e.Kind() == ast.CallKind && !e.AsCall().IsMemberFunction() {
+		fn := e.AsCall().FunctionName()
+		if r, found := info.GetOffsetRange(e.ID()); found && r.Start == start && isFunctionName(fn) {
+			start -= int32(len(fn))
+		}
+	}
+	return start, stop, ok
+}
+
+// isFunctionName 判断是否为源码中按名称调用的函数，运算符和内部函数以 _ 或 @ 开头
+func isFunctionName(name string) bool {
+	return name != "" && !strings.HasPrefix(name, "_") && !strings.HasPrefix(name, "@")
+}
+
+func formatExplainValue(v Val) string {
+	var s string
+	switch t := v.(type) {
+	case *types.Err:
+		s = "error: " + t.Error()
+	case *types.Unknown:
+		s = "unknown"
+	case types.String:
+		s = strconv.Quote(string(t))
+	case types.Double:
+		s = strconv.FormatFloat(float64(t), 'f', -1, 64)
+		if !strings.ContainsAny(s, ".eEnN") {
+			s += ".0"
+		}
+	default:
+		s = fmt.Sprint(v.Value())
+		// protobuf 的文本格式不稳定，转换为紧凑的 JSON
+		if msg, ok := v.Value().(proto.Message); ok {
+			var buf bytes.Buffer
+			if data, err := protojson.Marshal(msg); err == nil && json.Compact(&buf, data) == nil {
+				s = buf.String()
+			}
+		}
+	}
+	if utf8.RuneCountInString(s) > maxExplainValueLen {
+		s = string([]rune(s)[:maxExplainValueLen]) + "..."
+	}
+	return s
+}
+
+// String 以树的形式展示执行过程，每行为子表达式及其取值
+func (x *Explanation) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s => %s\n", x.Root.Expr, x.Root.Value)
+	writeExplainChildren(&b, x.Root, "")
+	return b.String()
+}
+
+func writeExplainChildren(b *strings.Builder, n *ExplainNode, prefix string) {
+	for i, child := range n.Children {
+		branch, next := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, next = "└── ", "    "
+		}
+		fmt.Fprintf(b, "%s%s%s => %s\n", prefix, branch, child.Expr, child.Value)
+		writeExplainChildren(b, child, prefix+next)
+	}
+}
@@ -0,0 +1,71 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zhijingtech/expr/testdata"
+)
+
+func TestExpr_Explain(t *testing.T) {
+	env, err := NewEnv(
+		Types(&testdata.Rectangle{}),
+		Variable("prev", ObjectType("testdata.Rectangle")),
+		Variable("current", ObjectType("testdata.Rectangle")),
+	)
+	assert.NoError(t, err)
+	e, err := NewExpr(exprstr, env)
+	assert.NoError(t, err)
+
+	x, err := e.Explain(map[string]any{
+		"prev":    &testdata.Rectangle{P1: &testdata.Point{X: 1, Y: 2}, P2: &testdata.Point{X: 3, Y: 4}},
+		"current": &testdata.Rectangle{P1: &testdata.Point{X: 5, Y: 7}, P2: &testdata.Point{X: 7, Y: 5}},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, x.Err)
+	assert.Equal(t, false, x.Value)
+	assert.Equal(t, `current.P1.X - prev.P2.X <= 1.0 || current.P1.Y - prev.P2.Y <= 1.0 => false
+├── current.P1.X - prev.P2.X <= 1.0 => false
+│   └── current.P1.X - prev.P2.X => 2.0
+│       ├── current.P1.X => 5.0
+│       │   └── current.P1 => {"X":5,"Y":7}
+│       └── prev.P2.X => 3.0
+│           └── prev.P2 => {"X":3,"Y":4}
+└── current.P1.Y - prev.P2.Y <= 1.0 => false
+    └── current.P1.Y - prev.P2.Y => 3.0
+        ├── current.P1.Y => 7.0
+        │   └── current.P1 => {"X":5,"Y":7}
+        └── prev.P2.Y => 4.0
+            └── prev.P2 => {"X":3,"Y":4}
+`, x.String())
+
+	cmp := x.Root.Children[1]
+	assert.Equal(t, "current.P1.Y - prev.P2.Y <= 1.0", cmp.Expr)
+	assert.Equal(t, 36, cmp.Offset)
+	assert.Equal(t, 67, cmp.End)
+	assert.Equal(t, 1, cmp.Line)
+	assert.Equal(t, 36, cmp.Column)
+}
+
+func TestExpr_Explain_Err(t *testing.T) {
+	e, err := NewExpr(`has(this.a) && this.a > 1 || this.items.exists(x, x == "b")`)
+	assert.NoError(t, err)
+
+	x, err := e.Explain(map[string]any{"this": map[string]any{"items": []string{"a", "b"}}})
+	assert.NoError(t, err)
+	assert.NoError(t, x.Err)
+	assert.Equal(t, true, x.Value)
+	assert.Equal(t, `has(this.a) && this.a > 1 || this.items.exists(x, x == "b") => true
+├── has(this.a) && this.a > 1 => false
+│   ├── has(this.a) => false
+│   └── this.a > 1 => error: no such key: a
+│       └── this.a => error: no such key: a
+└── this.items.exists(x, x == "b") => true
+`, x.String())
+
+	x, err = e.Explain(map[string]any{})
+	assert.NoError(t, err)
+	assert.EqualError(t, x.Err, "no such attribute(s): this")
+	assert.Nil(t, x.Value)
+}
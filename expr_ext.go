@@ -0,0 +1,75 @@
+package expr
+
+import (
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+)
+
+// 扩展库的版本，固定版本以免升级 cel-go 时可用的函数随之变化。
+// 提升版本前需确认新增的函数，并在 README 中说明。
+const (
+	stringsExtVersion  = 3
+	mathExtVersion     = 1
+	listsExtVersion    = 2
+	bindingsExtVersion = 1
+)
+
+// StandardEnv 在 DefaultEnv 的基础上包含 StandardExtensions 的环境
+var StandardEnv, _ = NewEnv(UseThisVariable(), StandardExtensions())
+
+// StandardExtensions 包含本包提供的全部 cel-go 扩展库：字符串、数学、列表、集合、编码和 cel.bind
+func StandardExtensions() Option {
+	return cel.Lib(standardExtensions{})
+}
+
+// standardExtensions 在同一个环境上依次引入各扩展库，不额外创建环境
+type standardExtensions struct{}
+
+func (standardExtensions) LibraryName() string {
+	return "expr.ext.standard"
+}
+
+func (standardExtensions) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		StringExtensions(),
+		MathExtensions(),
+		ListExtensions(),
+		SetExtensions(),
+		EncoderExtensions(),
+		BindingExtensions(),
+	}
+}
+
+func (standardExtensions) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+// StringExtensions 字符串扩展函数，如 lowerAscii、upperAscii、split、join、replace、trim、indexOf、substring、format、quote 和 reverse
+func StringExtensions() Option {
+	return ext.Strings(ext.StringsVersion(stringsExtVersion))
+}
+
+// MathExtensions 数学扩展函数，如 math.greatest、math.least、math.ceil、math.floor、math.round、math.abs、math.sign 和位运算
+func MathExtensions() Option {
+	return ext.Math(ext.MathVersion(mathExtVersion))
+}
+
+// ListExtensions 列表扩展函数，如 slice、flatten、distinct、range、reverse、sort 和 sortBy
+func ListExtensions() Option {
+	return ext.Lists(ext.ListsVersion(listsExtVersion))
+}
+
+// SetExtensions 集合扩展函数：sets.contains、sets.equivalent 和 sets.intersects
+func SetExtensions() Option {
+	return ext.Sets()
+}
+
+// EncoderExtensions 编码扩展函数：base64.encode 和 base64.decode
+func EncoderExtensions() Option {
+	return ext.Encoders()
+}
+
+// BindingExtensions 局部变量绑定宏 cel.bind(name, init, expr)
+func BindingExtensions() Option {
+	return ext.Bindings(ext.BindingsVersion(bindingsExtVersion))
+}
@@ -0,0 +1,67 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStandardExtensions(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{`"Hello".lowerAscii()`, "hello"},
+		{`"a,b".split(",")`, []string{"a", "b"}},
+		{`["a", "b"].join("-")`, "a-b"},
+		{`"abc".reverse()`, "cba"},
+		{`"%s=%d".format(["a", 1])`, "a=1"},
+		{`math.greatest(1, 3, 2)`, int64(3)},
+		{`math.round(1.5)`, 2.0},
+		{`math.bitAnd(6, 3)`, int64(2)},
+		{`[[1], [2, 3]].flatten()`, []any{int64(1), int64(2), int64(3)}},
+		{`[3, 1, 2].sort()`, []any{int64(1), int64(2), int64(3)}},
+		{`[1, 1, 2].distinct()`, []any{int64(1), int64(2)}},
+		{`["bb", "a"].sortBy(s, s.size())`, []any{"a", "bb"}},
+		{`sets.contains([1, 2, 3], [2])`, true},
+		{`base64.encode(b"hi")`, "aGk="},
+		{`cel.bind(x, this.v * 2, x + x)`, int64(8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, StandardEnv)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(WrapThisVariable(map[string]any{"v": 2}))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// DefaultEnv 只包含 CEL 内置函数
+	_, err := NewExpr(`"Hello".lowerAscii()`)
+	assert.Error(t, err)
+
+	// 扩展库可以单独使用
+	env, err := NewEnv(SetExtensions())
+	assert.NoError(t, err)
+	_, err = NewExpr(`sets.intersects([1], [1])`, env)
+	assert.NoError(t, err)
+	_, err = NewExpr(`"a".lowerAscii()`, env)
+	assert.Error(t, err)
+}
+
+func TestStandardExtensionsSameEnv(t *testing.T) {
+	// 扩展库在传入的环境上引入，后续选项拿到的是同一个环境
+	var before, after *cel.Env
+	_, err := NewEnv(
+		func(env *cel.Env) (*cel.Env, error) { before = env; return env, nil },
+		StandardExtensions(),
+		func(env *cel.Env) (*cel.Env, error) { after = env; return env, nil },
+		StandardExtensions(),
+	)
+	assert.NoError(t, err)
+	assert.Same(t, before, after)
+}
@@ -36,15 +36,21 @@ func ValOrErr(val Val, format string, args ...any) Val {
 }
 
 func UnaryBinding(binding UnaryOp) cel.OverloadOpt {
-	return cel.UnaryBinding(binding)
+	return cel.UnaryBinding(func(arg Val) Val {
+		return markFunctionErr(binding(arg))
+	})
 }
 
 func BinaryBinding(binding BinaryOp) cel.OverloadOpt {
-	return cel.BinaryBinding(binding)
+	return cel.BinaryBinding(func(lhs, rhs Val) Val {
+		return markFunctionErr(binding(lhs, rhs))
+	})
 }
 
 func FunctionBinding(binding FunctionOp) cel.OverloadOpt {
-	return cel.FunctionBinding(binding)
+	return cel.FunctionBinding(func(args ...Val) Val {
+		return markFunctionErr(binding(args...))
+	})
 }
 
 func Overload(overloadID string, args []*Type, resultType *Type, opts ...cel.OverloadOpt) cel.FunctionOpt {
@@ -0,0 +1,132 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+)
+
+// Func0 注册无参数的函数，返回值类型由 R 推导，类型映射和重载 ID 的规则同 GoFunction
+func Func0[R any](name string, fn func() R) Option {
+	return genericOverload(name, false, nil, typeFor[R](), func(adapter types.Adapter, id string) cel.OverloadOpt {
+		return FunctionBinding(func(...Val) Val {
+			return goFuncResult(adapter, fn())
+		})
+	})
+}
+
+// Func1 注册一个参数的函数，参数和返回值类型由 A、R 推导，执行时的参数已转换为 A
+func Func1[A, R any](name string, fn func(A) R) Option {
+	return genericOverload(name, false, []reflect.Type{typeFor[A]()}, typeFor[R](), unaryBinding(fn))
+}
+
+// Func2 注册两个参数的函数
+func Func2[A, B, R any](name string, fn func(A, B) R) Option {
+	return genericOverload(name, false, []reflect.Type{typeFor[A](), typeFor[B]()}, typeFor[R](), binaryBinding(fn))
+}
+
+// Func3 注册三个参数的函数
+func Func3[A, B, C, R any](name string, fn func(A, B, C) R) Option {
+	return genericOverload(name, false, []reflect.Type{typeFor[A](), typeFor[B](), typeFor[C]()}, typeFor[R](), ternaryBinding(fn))
+}
+
+// Method0 注册以 Recv 为接收者、没有其他参数的成员函数，按 x.name() 的形式调用
+func Method0[Recv, R any](name string, fn func(Recv) R) Option {
+	return genericOverload(name, true, []reflect.Type{typeFor[Recv]()}, typeFor[R](), unaryBinding(fn))
+}
+
+// Method1 注册以 Recv 为接收者、一个参数的成员函数，按 x.name(a) 的形式调用
+func Method1[Recv, A, R any](name string, fn func(Recv, A) R) Option {
+	return genericOverload(name, true, []reflect.Type{typeFor[Recv](), typeFor[A]()}, typeFor[R](), binaryBinding(fn))
+}
+
+// Method2 注册以 Recv 为接收者、两个参数的成员函数，按 x.name(a, b) 的形式调用
+func Method2[Recv, A, B, R any](name string, fn func(Recv, A, B) R) Option {
+	return genericOverload(name, true, []reflect.Type{typeFor[Recv](), typeFor[A](), typeFor[B]()}, typeFor[R](), ternaryBinding(fn))
+}
+
+type genericBinding func(adapter types.Adapter, id string) cel.OverloadOpt
+
+func genericOverload(name string, member bool, params []reflect.Type, result reflect.Type, binding genericBinding) Option {
+	return func(env *cel.Env) (*cel.Env, error) {
+		argTypes, err := goFuncTypes(params)
+		if err != nil {
+			return nil, fmt.Errorf("function %s: %w", name, err)
+		}
+		resultType, err := goFuncType(result)
+		if err != nil {
+			return nil, fmt.Errorf("function %s: %w", name, err)
+		}
+		id := overloadID(name, argTypes)
+		opt := binding(env.CELTypeAdapter(), id)
+		if member {
+			return Function(name, MemberOverload(id, argTypes, resultType, opt))(env)
+		}
+		return Function(name, Overload(id, argTypes, resultType, opt))(env)
+	}
+}
+
+func unaryBinding[A, R any](fn func(A) R) genericBinding {
+	return func(adapter types.Adapter, id string) cel.OverloadOpt {
+		return UnaryBinding(func(arg Val) Val {
+			a, err := nativeArg[A](arg)
+			if err != nil {
+				return types.NewErr("%s: argument 0: %v", id, err)
+			}
+			return goFuncResult(adapter, fn(a))
+		})
+	}
+}
+
+func binaryBinding[A, B, R any](fn func(A, B) R) genericBinding {
+	return func(adapter types.Adapter, id string) cel.OverloadOpt {
+		return BinaryBinding(func(lhs, rhs Val) Val {
+			a, err := nativeArg[A](lhs)
+			if err != nil {
+				return types.NewErr("%s: argument 0: %v", id, err)
+			}
+			b, err := nativeArg[B](rhs)
+			if err != nil {
+				return types.NewErr("%s: argument 1: %v", id, err)
+			}
+			return goFuncResult(adapter, fn(a, b))
+		})
+	}
+}
+
+func ternaryBinding[A, B, C, R any](fn func(A, B, C) R) genericBinding {
+	return func(adapter types.Adapter, id string) cel.OverloadOpt {
+		return FunctionBinding(func(args ...Val) Val {
+			a, err := nativeArg[A](args[0])
+			if err != nil {
+				return types.NewErr("%s: argument 0: %v", id, err)
+			}
+			b, err := nativeArg[B](args[1])
+			if err != nil {
+				return types.NewErr("%s: argument 1: %v", id, err)
+			}
+			c, err := nativeArg[C](args[2])
+			if err != nil {
+				return types.NewErr("%s: argument 2: %v", id, err)
+			}
+			return goFuncResult(adapter, fn(a, b, c))
+		})
+	}
+}
+
+// nativeArg 将 cel 的值转换为类型 T
+func nativeArg[T any](arg Val) (T, error) {
+	var zero T
+	rv, err := goFuncArg(arg, typeFor[T]())
+	if err != nil {
+		return zero, err
+	}
+	v, _ := rv.Interface().(T)
+	return v, nil
+}
+
+func typeFor[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
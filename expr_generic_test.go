@@ -0,0 +1,68 @@
+package expr
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenericFunctions(t *testing.T) {
+	env, err := NewEnv(
+		UseThisVariable(),
+		Func0("pi", func() float64 { return math.Pi }),
+		Func1("twice", func(a int64) int64 { return a * 2 }),
+		Func1("twice", func(s string) string { return s + s }),
+		Func2("distance", func(a, b float64) float64 { return math.Abs(a - b) }),
+		Func3("clamp", func(v, lo, hi int) int { return max(lo, min(v, hi)) }),
+		Func1("len", func(v any) int { return len(v.([]any)) }),
+		Func1("isErr", func(v Val) bool { _, ok := v.(Error); return ok }),
+		Method0("upper", func(s string) string { return strings.ToUpper(s) }),
+		Method1("truncate", func(t time.Time, d time.Duration) time.Time { return t.Truncate(d) }),
+		Method2("pad", func(s string, n int, c string) string { return s + strings.Repeat(c, n) }),
+		Func1("fail", func(s string) Val { return NewErr("failed: %s", s) }),
+	)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		expression string
+		want       any
+		wantErr    string
+	}{
+		{expression: `pi() > 3.14`, want: true},
+		{expression: `twice(2)`, want: int64(4)},
+		{expression: `twice("a")`, want: "aa"},
+		{expression: `distance(this.X, this.Y) < 1.0`, want: true},
+		{expression: `clamp(10, 0, 5)`, want: int64(5)},
+		{expression: `len([1, "a"])`, want: int64(2)},
+		{expression: `isErr(1)`, want: false},
+		{expression: `"abc".upper()`, want: "ABC"},
+		{expression: `timestamp("2024-01-01T10:30:00Z").truncate(duration("1h")) == timestamp("2024-01-01T10:00:00Z")`, want: true},
+		{expression: `"a".pad(2, "-")`, want: "a--"},
+		{expression: `fail("x")`, wantErr: "failed: x"},
+		{expression: `twice(this.Y)`, wantErr: "no such overload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(WrapThisVariable(map[string]any{"X": 3.0, "Y": 3.5}))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = NewExpr(`distance(1, 2)`, env)
+	assert.ErrorContains(t, err, "found no matching overload for 'distance' applied to '(int, int)'")
+
+	_, err = NewEnv(Func1("f", func(chan int) int { return 0 }))
+	assert.EqualError(t, err, "function f: unsupported go type: chan int")
+}
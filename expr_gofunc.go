@@ -0,0 +1,413 @@
+package expr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+)
+
+// maxVariadicArgs 可变参数函数注册的最大参数个数，每个参数个数对应一个重载
+const maxVariadicArgs = 8
+
+// contextVarName 执行时传递 context.Context 的内部变量名，不是合法的 CEL 标识符，不会与用户变量冲突
+const contextVarName = "@context"
+
+var (
+	typeOfContext = reflect.TypeOf((*context.Context)(nil)).Elem()
+	typeOfError   = reflect.TypeOf((*error)(nil)).Elem()
+	typeOfVal     = reflect.TypeOf((*Val)(nil)).Elem()
+)
+
+// GoFunction 将普通的 Go 函数注册为 CEL 函数，fns 中的每个函数为一个重载，
+// 重载 ID、参数类型和返回值类型由函数签名推导，如 func(a, b float64) float64 的重载 ID 为 name_double_double。
+//   - 参数支持 Go 的基础类型、time.Time、time.Duration、[]byte、切片、map、ProtoBuf 消息、
+//     已通过 NativeTypes 声明的结构体，any 类型的参数按 DefaultDecoder 解析，Val 类型的参数原样传入；
+//   - 第一个参数可以是 context.Context，通过 EvalContext 执行时传入其 ctx，否则传入 context.Background()，
+//     参数为 dyn 类型、执行时才能确定重载的调用同样传入 ctx；
+//   - 可变参数函数注册为最多 8 个可变参数的多个重载；
+//   - 返回值为 T 或 (T, error)，返回的 error 作为表达式的执行错误。
+func GoFunction(name string, fns ...any) Option {
+	return goFunctionOption(name, false, fns)
+}
+
+// GoMethod 同 GoFunction，但以函数的第一个参数（context.Context 之后）作为接收者，按 x.name(...) 的形式调用
+func GoMethod(name string, fns ...any) Option {
+	return goFunctionOption(name, true, fns)
+}
+
+func goFunctionOption(name string, member bool, fns []any) Option {
+	return func(env *cel.Env) (*cel.Env, error) {
+		lib := &goFuncLib{name: name, contextFuncs: map[string]*goFunc{}}
+		for _, fn := range fns {
+			overloads, err := newGoFuncs(name, member, fn, env.CELTypeAdapter())
+			if err != nil {
+				return nil, fmt.Errorf("function %s: %w", name, err)
+			}
+			for _, f := range overloads {
+				lib.funcs = append(lib.funcs, f)
+				lib.overloads = append(lib.overloads, f.overload())
+				if f.withContext {
+					lib.contextFuncs[f.id] = f
+				}
+			}
+		}
+		return cel.Lib(lib)(env)
+	}
+}
+
+// goFuncLib 每次应用 GoFunction、GoMethod 时创建的函数库，自身保存需要 context.Context 的函数，
+// 通过执行选项中的装饰器向这些函数传入 EvalContext 的 ctx
+type goFuncLib struct {
+	name      string
+	funcs     []*goFunc
+	overloads []cel.FunctionOpt
+	// contextFuncs 需要 context.Context 的函数，key 为重载 ID
+	contextFuncs map[string]*goFunc
+}
+
+func (l *goFuncLib) LibraryName() string {
+	return "expr.gofunc." + l.name
+}
+
+func (l *goFuncLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{Function(l.name, l.overloads...)}
+}
+
+func (l *goFuncLib) ProgramOptions() []cel.ProgramOption {
+	if len(l.contextFuncs) == 0 {
+		return nil
+	}
+	return []cel.ProgramOption{cel.CustomDecorator(l.decorate)}
+}
+
+// decorate 使需要 context.Context 的函数在执行时获取 EvalContext 的 ctx
+func (l *goFuncLib) decorate(i interpreter.Interpretable) (interpreter.Interpretable, error) {
+	call, ok := i.(interpreter.InterpretableCall)
+	if !ok || call.Function() != l.name {
+		return i, nil
+	}
+	if f, found := l.contextFuncs[call.OverloadID()]; found {
+		return &contextCall{InterpretableCall: call, fn: f}, nil
+	}
+	// 参数类型不确定的调用没有重载 ID，数值类型自动转换生成的重载不在 contextFuncs 中，都在执行时按参数选择重载
+	switch id := call.OverloadID(); {
+	case id == "":
+		return &contextCall{InterpretableCall: call, lib: l}, nil
+	case strings.HasSuffix(id, coerceOverloadSuffix):
+		return &contextCall{InterpretableCall: call, lib: l, coerce: true}, nil
+	}
+	return i, nil
+}
+
+// dispatch 返回参数的运行时类型与之匹配的第一个重载，coerce 为 true 时没有完全匹配的重载则按数值类型自动转换的规则选择，
+// 返回的参数已转换为重载的参数类型；没有匹配的重载时返回 nil，数值转换溢出时返回错误
+func (l *goFuncLib) dispatch(args []Val, coerce bool) (*goFunc, []Val, Val) {
+	for _, f := range l.funcs {
+		if f.matches(args) {
+			return f, args, nil
+		}
+	}
+	if !coerce {
+		return nil, nil, nil
+	}
+	var best *goFunc
+	bestConversions, bestIntUint := 0, 0
+	for _, f := range l.funcs {
+		conversions, intUint, ok := f.coercions(args)
+		if ok && (best == nil || conversions < bestConversions || conversions == bestConversions && intUint < bestIntUint) {
+			best, bestConversions, bestIntUint = f, conversions, intUint
+		}
+	}
+	if best == nil {
+		return nil, nil, nil
+	}
+	converted := make([]Val, len(args))
+	for i, arg := range args {
+		converted[i] = arg
+		if !best.argTypes[i].IsAssignableRuntimeType(arg) {
+			if converted[i] = arg.ConvertToType(best.argTypes[i]); types.IsError(converted[i]) {
+				return nil, nil, converted[i]
+			}
+		}
+	}
+	return best, converted, nil
+}
+
+// goFunc 由 Go 函数推导出的一个重载
+type goFunc struct {
+	id          string
+	member      bool
+	fn          reflect.Value
+	withContext bool
+	// params 除 context.Context 外的参数类型，可变参数已展开
+	params     []reflect.Type
+	argTypes   []*Type
+	resultType *Type
+	adapter    types.Adapter
+}
+
+func newGoFuncs(name string, member bool, fn any, adapter types.Adapter) ([]*goFunc, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		return nil, fmt.Errorf("expected a func, got %T", fn)
+	}
+	ft := fv.Type()
+	switch {
+	case ft.NumOut() == 1 && ft.Out(0) != typeOfError:
+	case ft.NumOut() == 2 && ft.Out(1) == typeOfError:
+	default:
+		return nil, fmt.Errorf("%v must return T or (T, error)", ft)
+	}
+	resultType, err := goFuncType(ft.Out(0))
+	if err != nil {
+		return nil, err
+	}
+
+	var params []reflect.Type
+	for i := 0; i < ft.NumIn(); i++ {
+		params = append(params, ft.In(i))
+	}
+	withContext := len(params) > 0 && params[0] == typeOfContext
+	if withContext {
+		params = params[1:]
+	}
+	arities := []int{len(params)}
+	var variadic reflect.Type
+	if ft.IsVariadic() {
+		variadic = params[len(params)-1].Elem()
+		params = params[:len(params)-1]
+		arities = arities[:0]
+		for n := 0; n <= maxVariadicArgs; n++ {
+			arities = append(arities, len(params)+n)
+		}
+	}
+	var overloads []*goFunc
+	for _, arity := range arities {
+		f := &goFunc{member: member, fn: fv, withContext: withContext, resultType: resultType, adapter: adapter}
+		f.params = append(f.params, params...)
+		for len(f.params) < arity {
+			f.params = append(f.params, variadic)
+		}
+		if member && len(f.params) == 0 {
+			if variadic != nil {
+				continue
+			}
+			return nil, fmt.Errorf("%v has no receiver parameter", ft)
+		}
+		if f.argTypes, err = goFuncTypes(f.params); err != nil {
+			return nil, err
+		}
+		f.id = overloadID(name, f.argTypes)
+		overloads = append(overloads, f)
+	}
+	return overloads, nil
+}
+
+// goFuncType 返回 Go 函数参数或返回值对应的 cel 类型
+func goFuncType(typ reflect.Type) (*Type, error) {
+	if typ == typeOfVal {
+		return DynType, nil
+	}
+	return celTypeOf(typ)
+}
+
+func goFuncTypes(params []reflect.Type) ([]*Type, error) {
+	argTypes := make([]*Type, len(params))
+	for i, p := range params {
+		t, err := goFuncType(p)
+		if err != nil {
+			return nil, err
+		}
+		argTypes[i] = t
+	}
+	return argTypes, nil
+}
+
+// overloadID 由函数名和参数类型推导重载 ID
+func overloadID(name string, argTypes []*Type) string {
+	ids := []string{name}
+	for _, t := range argTypes {
+		ids = append(ids, overloadTypeName(t))
+	}
+	return strings.Join(ids, "_")
+}
+
+// overloadTypeName 返回用于重载 ID 的类型名，如 list(int) 为 list_int
+func overloadTypeName(t *Type) string {
+	return strings.Join(strings.FieldsFunc(t.String(), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "_")
+}
+
+func (f *goFunc) overload() cel.FunctionOpt {
+	binding := FunctionBinding(func(args ...Val) Val {
+		return f.invoke(context.Background(), args)
+	})
+	if f.member {
+		return MemberOverload(f.id, f.argTypes, f.resultType, binding)
+	}
+	return Overload(f.id, f.argTypes, f.resultType, binding)
+}
+
+// matches 判断参数的运行时类型是否与重载的参数类型匹配
+func (f *goFunc) matches(args []Val) bool {
+	if len(args) != len(f.argTypes) {
+		return false
+	}
+	for i, arg := range args {
+		if !f.argTypes[i].IsAssignableRuntimeType(arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// coercions 判断参数经数值类型转换后能否匹配重载，返回需要转换的参数个数和其中 int、uint 互相转换的个数
+func (f *goFunc) coercions(args []Val) (conversions, intUint int, ok bool) {
+	if len(args) != len(f.argTypes) {
+		return 0, 0, false
+	}
+	for i, arg := range args {
+		param := f.argTypes[i]
+		switch {
+		case param.IsAssignableRuntimeType(arg):
+		case isNumericType(param) && numericType(arg) != nil:
+			conversions++
+			if !param.IsExactType(DoubleType) {
+				intUint++
+			}
+		default:
+			return 0, 0, false
+		}
+	}
+	return conversions, intUint, true
+}
+
+func (f *goFunc) invoke(ctx context.Context, args []Val) Val {
+	in := make([]reflect.Value, 0, len(args)+1)
+	if f.withContext {
+		in = append(in, reflect.ValueOf(&ctx).Elem())
+	}
+	for i, arg := range args {
+		v, err := goFuncArg(arg, f.params[i])
+		if err != nil {
+			return types.NewErr("%s: argument %d: %v", f.id, i, err)
+		}
+		in = append(in, v)
+	}
+	out := f.fn.Call(in)
+	if len(out) == 2 && !out[1].IsNil() {
+		return types.WrapErr(out[1].Interface().(error))
+	}
+	return goFuncResult(f.adapter, out[0].Interface())
+}
+
+// goFuncResult 将 Go 函数的返回值转换为 cel 的值
+func goFuncResult(adapter types.Adapter, v any) Val {
+	if v, ok := v.(Val); ok {
+		return v
+	}
+	return adapter.NativeToValue(v)
+}
+
+// goFuncArg 将 cel 的值转换为 Go 函数的参数
+func goFuncArg(arg Val, typ reflect.Type) (reflect.Value, error) {
+	switch {
+	case typ == typeOfVal:
+		return reflect.ValueOf(&arg).Elem(), nil
+	case typ.Kind() == reflect.Interface && typ.NumMethod() == 0:
+		v, err := DefaultDecoder.Decode(arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if v == nil {
+			return reflect.Zero(typ), nil
+		}
+		return reflect.ValueOf(v), nil
+	}
+	v, err := arg.ConvertToNative(typ)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return reflect.Zero(typ), nil
+	}
+	if rv.Type() != typ {
+		if !rv.Type().ConvertibleTo(typ) {
+			return reflect.Value{}, errors.New("type conversion error from '" + rv.Type().String() + "' to '" + typ.String() + "'")
+		}
+		rv = rv.Convert(typ)
+	}
+	return rv, nil
+}
+
+// contextCall 向 Go 函数传入 EvalContext 的 ctx 的调用，fn 为空时在执行时从 lib 中按参数选择重载，
+// coerce 表示环境启用了数值类型自动转换，选择重载时可以转换数值参数
+type contextCall struct {
+	interpreter.InterpretableCall
+	fn     *goFunc
+	lib    *goFuncLib
+	coerce bool
+}
+
+func (c *contextCall) Eval(vars interpreter.Activation) Val {
+	argExprs := c.Args()
+	args := make([]Val, len(argExprs))
+	for i, a := range argExprs {
+		v := a.Eval(vars)
+		if types.IsUnknownOrError(v) {
+			return v
+		}
+		args[i] = v
+	}
+	fn := c.fn
+	if fn == nil {
+		var errVal Val
+		if fn, args, errVal = c.lib.dispatch(args, c.coerce); errVal != nil {
+			return types.LabelErrNode(c.ID(), errVal)
+		}
+		if fn == nil {
+			// 同名函数中还有其他库声明的重载，由 cel-go 按原方式分派
+			return c.InterpretableCall.Eval(vars)
+		}
+	}
+	ctx := context.Background()
+	if v, found := vars.ResolveName(contextVarName); found {
+		ctx = v.(context.Context)
+	}
+	return types.LabelErrNode(c.ID(), markFunctionErr(fn.invoke(ctx, args)))
+}
+
+// contextInput 将 ctx 附加到执行的入参中，供需要 context.Context 的函数和时间函数使用
+func contextInput(ctx context.Context, input any) (any, error) {
+	vars, err := interpreter.NewActivation(input)
+	if err != nil {
+		return nil, err
+	}
+	return &contextActivation{Activation: vars, ctx: ctx}, nil
+}
+
+// contextActivation 通过内部变量向需要 context.Context 的函数传递 ctx
+type contextActivation struct {
+	interpreter.Activation
+	ctx context.Context
+}
+
+func (a *contextActivation) ResolveName(name string) (any, bool) {
+	if name == contextVarName {
+		return a.ctx, true
+	}
+	return a.Activation.ResolveName(name)
+}
+
+func (a *contextActivation) Parent() interpreter.Activation {
+	return a.Activation
+}
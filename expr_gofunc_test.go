@@ -0,0 +1,179 @@
+package expr
+
+import (
+	"context"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/stretchr/testify/assert"
+)
+
+type gofuncKey struct{}
+
+func TestGoFunction(t *testing.T) {
+	env, err := NewEnv(
+		UseThisVariable(),
+		GoFunction("distance", func(a, b float64) float64 { return math.Abs(a - b) }),
+		GoFunction("join",
+			func(sep string, parts ...string) string { return strings.Join(parts, sep) },
+			func(parts []string) string { return strings.Join(parts, ",") },
+		),
+		GoFunction("parsePort", func(s string) (int, error) {
+			if s == "" {
+				return 0, errors.New("empty port")
+			}
+			return len(s), nil
+		}),
+		GoFunction("after", func(t time.Time, d time.Duration) time.Time { return t.Add(d) }),
+		GoFunction("keys", func(m map[string]int) []string {
+			var keys []string
+			for k := range m {
+				keys = append(keys, k)
+			}
+			return keys
+		}),
+		GoFunction("describe", func(v any) string { return strings.TrimSpace(strings.Repeat("x ", len(v.([]any)))) }),
+		GoFunction("trace", func(ctx context.Context, s string) string {
+			if v, ok := ctx.Value(gofuncKey{}).(string); ok {
+				return v + ":" + s
+			}
+			return s
+		}),
+		GoMethod("repeat", func(s string, n int) string { return strings.Repeat(s, n) }),
+	)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		expression string
+		want       any
+		wantErr    string
+	}{
+		{expression: `distance(this.X, this.Y) < 1.0`, want: true},
+		{expression: `join("-")`, want: ""},
+		{expression: `join("-", "a", "b", "c")`, want: "a-b-c"},
+		{expression: `join(["a", "b"])`, want: "a,b"},
+		{expression: `parsePort("80")`, want: int64(2)},
+		{expression: `parsePort("")`, wantErr: "empty port"},
+		{expression: `after(timestamp("2024-01-01T00:00:00Z"), duration("1h")) == timestamp("2024-01-01T01:00:00Z")`, want: true},
+		{expression: `keys({"a": 1})`, want: []string{"a"}},
+		{expression: `describe([1, 2])`, want: "x x"},
+		{expression: `trace("a")`, want: "a"},
+		{expression: `"ab".repeat(2)`, want: "abab"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(WrapThisVariable(map[string]any{"X": 3.0, "Y": 3.5}))
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				assert.ErrorIs(t, err, ErrFunction)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// 推导的重载 ID
+	assert.True(t, env.env.HasFunction("join"))
+	var ids []string
+	for _, o := range env.env.Functions()["distance"].OverloadDecls() {
+		ids = append(ids, o.ID())
+	}
+	assert.Equal(t, []string{"distance_double_double"}, ids)
+
+	// 通过 EvalContext 执行时传入 ctx，Extend 后的环境同样生效
+	ext, err := env.Extend(Variable("s", StringType))
+	assert.NoError(t, err)
+	for _, e := range []*Env{env, ext} {
+		expr, err := NewExpr(`trace("a")`, e)
+		assert.NoError(t, err)
+		got, err := expr.EvalContext(context.WithValue(context.Background(), gofuncKey{}, "req-1"), map[string]any{})
+		assert.NoError(t, err)
+		assert.Equal(t, "req-1:a", got)
+	}
+}
+
+func TestGoFunction_ContextWithLaterOptions(t *testing.T) {
+	tag := GoFunction("tag", func(ctx context.Context, s string) string {
+		v, _ := ctx.Value(gofuncKey{}).(string)
+		return v + ":" + s
+	})
+	// 之后的选项替换了 cel 环境，或者直接传给 cel.NewEnv 后再包装，都不影响 ctx 的传递
+	env, err := NewEnv(tag, StandardExtensions(), Variable("s", StringType))
+	if !assert.NoError(t, err) {
+		return
+	}
+	celEnv, err := cel.NewEnv(tag)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, e := range []*Env{env, FromCelEnv(celEnv)} {
+		expr, err := NewExpr(`tag("a")`, e)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		got, err := expr.EvalContext(context.WithValue(context.Background(), gofuncKey{}, "req-2"), map[string]any{})
+		assert.NoError(t, err)
+		assert.Equal(t, "req-2:a", got)
+	}
+}
+
+func TestGoFunction_ContextDynamicDispatch(t *testing.T) {
+	env, err := NewEnv(UseThisVariable(), GoFunction("tag",
+		func(ctx context.Context, s string) string {
+			v, _ := ctx.Value(gofuncKey{}).(string)
+			return v + ":" + s
+		},
+		func(ctx context.Context, n int) string {
+			v, _ := ctx.Value(gofuncKey{}).(string)
+			return v + ":" + strings.Repeat("#", n)
+		},
+		func(b bool) string { return "bool" },
+	))
+	assert.NoError(t, err)
+	// this.v 为 dyn 类型，执行时才能确定重载
+	e, err := NewExpr(`tag(this.v)`, env)
+	assert.NoError(t, err)
+	ctx := context.WithValue(context.Background(), gofuncKey{}, "req-3")
+	for _, tt := range []struct {
+		v    any
+		want string
+	}{
+		{v: "a", want: "req-3:a"},
+		{v: 2, want: "req-3:##"},
+		{v: true, want: "bool"},
+	} {
+		got, err := e.EvalContext(ctx, WrapThisVariable(map[string]any{"v": tt.v}))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+	_, err = e.EvalContext(ctx, WrapThisVariable(map[string]any{"v": 1.5}))
+	assert.ErrorIs(t, err, ErrNoMatchingOverload)
+}
+
+func TestGoFunction_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      any
+		wantErr string
+	}{
+		{"not a func", 1, "function f: expected a func, got int"},
+		{"no result", func(int) {}, "function f: func(int) must return T or (T, error)"},
+		{"only error", func(int) error { return nil }, "function f: func(int) error must return T or (T, error)"},
+		{"unsupported type", func(chan int) int { return 0 }, "function f: unsupported go type: chan int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewEnv(GoFunction("f", tt.fn))
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
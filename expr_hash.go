@@ -0,0 +1,237 @@
+package expr
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"net/url"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+)
+
+type hashLib struct{}
+
+// HashFunctions 哈希、编码和 UUID 函数，参数 x 可以是 string 或 bytes：
+//   - hash.sha256(x)、hash.md5(x)：返回摘要的 bytes，hash.sha256Hex(x)、hash.md5Hex(x) 返回十六进制字符串；
+//   - hash.fnv32(x)、hash.fnv64(x)、hash.murmur3(x)：返回 FNV-1a 和 MurmurHash3（32 位，种子为 0）的 uint 值，
+//     结果在不同进程和版本间保持稳定，可用于分桶，如 hash.fnv64(this.user_id) % 100 < 10；
+//   - uint % int：分桶使用的取模运算，按数值计算，结果为 int，超出 int 范围的哈希值取模时不报溢出；
+//   - base64.encode(b)、base64.decode(s)：同 EncoderExtensions；
+//   - base64url.encode(b)、base64url.decode(s)：URL 安全的 base64 编码，不含填充，解码时允许填充；
+//   - hex.encode(b)、hex.decode(s)：十六进制编码；
+//   - url.encode(s)、url.decode(s)：URL 查询参数的编码；
+//   - isUUID(s)、uuid.parse(s)：校验 UUID 并返回小写带连字符的标准形式，支持大写、无连字符、{} 包裹和 urn:uuid: 前缀。
+func HashFunctions() Option {
+	return cel.Lib(hashLib{})
+}
+
+func (hashLib) LibraryName() string {
+	return "expr.hash"
+}
+
+func (hashLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		EncoderExtensions(),
+		hashFunction("hash.sha256", BytesType, func(b []byte) Val {
+			sum := sha256.Sum256(b)
+			return types.Bytes(sum[:])
+		}),
+		hashFunction("hash.sha256Hex", StringType, func(b []byte) Val {
+			sum := sha256.Sum256(b)
+			return types.String(hex.EncodeToString(sum[:]))
+		}),
+		hashFunction("hash.md5", BytesType, func(b []byte) Val {
+			sum := md5.Sum(b)
+			return types.Bytes(sum[:])
+		}),
+		hashFunction("hash.md5Hex", StringType, func(b []byte) Val {
+			sum := md5.Sum(b)
+			return types.String(hex.EncodeToString(sum[:]))
+		}),
+		hashFunction("hash.fnv32", UintType, func(b []byte) Val {
+			h := fnv.New32a()
+			h.Write(b)
+			return types.Uint(h.Sum32())
+		}),
+		hashFunction("hash.fnv64", UintType, func(b []byte) Val {
+			h := fnv.New64a()
+			h.Write(b)
+			return types.Uint(h.Sum64())
+		}),
+		hashFunction("hash.murmur3", UintType, func(b []byte) Val {
+			return types.Uint(murmur3(b, 0))
+		}),
+		// 与 WithNumericCoercion 声明的重载 ID 和签名相同，两者可以同时使用；
+		// 标准库的 _%_ 不能再添加实现，由 ProgramOptions 中的装饰器执行
+		Function(operators.Modulo, Overload(moduloUintIntID, []*Type{UintType, IntType}, IntType)),
+		Function("base64url.encode", Overload("base64url_encode_bytes", []*Type{BytesType}, StringType,
+			UnaryBinding(func(b Val) Val {
+				return types.String(base64.RawURLEncoding.EncodeToString(b.(types.Bytes)))
+			}))),
+		Function("base64url.decode", Overload("base64url_decode_string", []*Type{StringType}, BytesType,
+			UnaryBinding(func(s Val) Val {
+				b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(string(s.(types.String)), "="))
+				return bytesOrErr("base64url.decode", b, err)
+			}))),
+		Function("hex.encode", Overload("hex_encode_bytes", []*Type{BytesType}, StringType,
+			UnaryBinding(func(b Val) Val {
+				return types.String(hex.EncodeToString(b.(types.Bytes)))
+			}))),
+		Function("hex.decode", Overload("hex_decode_string", []*Type{StringType}, BytesType,
+			UnaryBinding(func(s Val) Val {
+				b, err := hex.DecodeString(string(s.(types.String)))
+				return bytesOrErr("hex.decode", b, err)
+			}))),
+		Function("url.encode", Overload("url_encode_string", []*Type{StringType}, StringType,
+			UnaryBinding(func(s Val) Val {
+				return types.String(url.QueryEscape(string(s.(types.String))))
+			}))),
+		Function("url.decode", Overload("url_decode_string", []*Type{StringType}, StringType,
+			UnaryBinding(func(s Val) Val {
+				decoded, err := url.QueryUnescape(string(s.(types.String)))
+				if err != nil {
+					return types.NewErr("url.decode: %v", err)
+				}
+				return types.String(decoded)
+			}))),
+		Function("isUUID", Overload("isUUID_string", []*Type{StringType}, BoolType,
+			UnaryBinding(func(s Val) Val {
+				_, err := parseUUID(string(s.(types.String)))
+				return types.Bool(err == nil)
+			}))),
+		Function("uuid.parse", Overload("uuid_parse_string", []*Type{StringType}, StringType,
+			UnaryBinding(func(s Val) Val {
+				uuid, err := parseUUID(string(s.(types.String)))
+				if err != nil {
+					return types.NewErr("uuid.parse: %v", err)
+				}
+				return types.String(uuid)
+			}))),
+	}
+}
+
+func (hashLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{cel.CustomDecorator(decorateModuloUintInt)}
+}
+
+// moduloUintIntID 分桶使用的 uint % int 的重载 ID
+const moduloUintIntID = "modulo_uint_int" + coerceOverloadSuffix
+
+// moduloUintIntCall 执行 uint % int，结果为 int
+type moduloUintIntCall struct {
+	interpreter.InterpretableCall
+}
+
+func decorateModuloUintInt(i interpreter.Interpretable) (interpreter.Interpretable, error) {
+	if call, ok := i.(interpreter.InterpretableCall); ok && call.OverloadID() == moduloUintIntID {
+		return &moduloUintIntCall{InterpretableCall: call}, nil
+	}
+	return i, nil
+}
+
+func (c *moduloUintIntCall) Eval(vars interpreter.Activation) Val {
+	args := c.Args()
+	lhs := args[0].Eval(vars)
+	if types.IsUnknownOrError(lhs) {
+		return lhs
+	}
+	rhs := args[1].Eval(vars)
+	if types.IsUnknownOrError(rhs) {
+		return rhs
+	}
+	l, lok := lhs.(types.Uint)
+	if _, rok := rhs.(types.Int); !lok || !rok {
+		return types.LabelErrNode(c.ID(), types.NoSuchOverloadErr())
+	}
+	if out, ok := moduloMixed(lhs, rhs); ok {
+		return types.LabelErrNode(c.ID(), out)
+	}
+	return types.LabelErrNode(c.ID(), types.Int(l).Modulo(rhs))
+}
+
+// hashFunction 声明参数为 string 或 bytes 的哈希函数
+func hashFunction(name string, resultType *Type, fn func(b []byte) Val) cel.EnvOption {
+	id := strings.ReplaceAll(name, ".", "_")
+	return Function(name,
+		Overload(id+"_string", []*Type{StringType}, resultType, UnaryBinding(func(s Val) Val {
+			return fn([]byte(s.(types.String)))
+		})),
+		Overload(id+"_bytes", []*Type{BytesType}, resultType, UnaryBinding(func(b Val) Val {
+			return fn(b.(types.Bytes))
+		})),
+	)
+}
+
+func bytesOrErr(fn string, b []byte, err error) Val {
+	if err != nil {
+		return types.NewErr("%s: %v", fn, err)
+	}
+	return types.Bytes(b)
+}
+
+// parseUUID 返回 UUID 小写带连字符的标准形式
+func parseUUID(s string) (string, error) {
+	raw := strings.TrimPrefix(strings.ToLower(s), "urn:uuid:")
+	if strings.HasPrefix(raw, "{") && strings.HasSuffix(raw, "}") {
+		raw = raw[1 : len(raw)-1]
+	}
+	if len(raw) == 36 {
+		if raw[8] != '-' || raw[13] != '-' || raw[18] != '-' || raw[23] != '-' {
+			return "", fmt.Errorf("invalid UUID %q", s)
+		}
+		raw = strings.ReplaceAll(raw, "-", "")
+	}
+	b, err := hex.DecodeString(raw)
+	if err != nil || len(b) != 16 {
+		return "", fmt.Errorf("invalid UUID %q", s)
+	}
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:], nil
+}
+
+// murmur3 计算 32 位的 MurmurHash3
+func murmur3(data []byte, seed uint32) uint32 {
+	const c1, c2 = 0xcc9e2d51, 0x1b873593
+	h := seed
+	n := len(data) / 4 * 4
+	for i := 0; i < n; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+	var k uint32
+	switch tail := data[n:]; len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
@@ -0,0 +1,163 @@
+package expr
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashFunctions(t *testing.T) {
+	env, err := DefaultEnv.Extend(HashFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	input := WrapThisVariable(map[string]any{"user_id": "u-1001", "id": "6BA7B810-9DAD-11D1-80B4-00C04FD430C8"})
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{`hash.sha256Hex("abc")`, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{`hash.sha256(b"abc") == hex.decode("ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad")`, true},
+		{`hash.md5Hex(b"abc")`, "900150983cd24fb0d6963f7d28e17f72"},
+		{`hex.encode(hash.md5("abc"))`, "900150983cd24fb0d6963f7d28e17f72"},
+		{`hash.fnv32("a")`, uint64(0xe40c292c)},
+		{`hash.fnv64("")`, uint64(0xcbf29ce484222325)},
+		{`hash.murmur3("")`, uint64(0)},
+		{`hash.murmur3("hello")`, uint64(0x248bfa47)},
+		{`hash.murmur3(b"The quick brown fox jumps over the lazy dog")`, uint64(0x2e4ff723)},
+		{`hash.fnv64(this.user_id) == hash.fnv64(bytes(this.user_id))`, true},
+		{`hash.fnv64(this.user_id) % 100u < 100u`, true},
+		{`base64.encode(b"hi?")`, "aGk/"},
+		{`base64.decode("aGk/")`, []byte("hi?")},
+		{`base64url.encode(b"hi?")`, "aGk_"},
+		{`base64url.decode("aGk_")`, []byte("hi?")},
+		{`base64url.decode("aGk=")`, []byte("hi")},
+		{`hex.encode(b"\x01\xff")`, "01ff"},
+		{`hex.decode("01FF")`, []byte{0x01, 0xff}},
+		{`url.encode("a b&c=d")`, "a+b%26c%3Dd"},
+		{`url.decode("a+b%26c%3Dd")`, "a b&c=d"},
+		{`isUUID(this.id)`, true},
+		{`isUUID("6ba7b810-9dad-11d1-80b4")`, false},
+		{`uuid.parse(this.id)`, "6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{`uuid.parse("{6ba7b8109dad11d180b400c04fd430c8}")`, "6ba7b810-9dad-11d1-80b4-00c04fd430c8"},
+		{`uuid.parse("urn:uuid:6ba7b810-9dad-11d1-80b4-00c04fd430c8") == uuid.parse(this.id)`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHashFunctionsErrors(t *testing.T) {
+	env, err := DefaultEnv.Extend(HashFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	tests := []struct {
+		expression string
+		errMsg     string
+	}{
+		{`hex.decode("xyz")`, "hex.decode: "},
+		{`base64url.decode("a")`, "base64url.decode: "},
+		{`url.decode("%zz")`, "url.decode: "},
+		{`uuid.parse("6ba7b810-9dad-11d1-80b4-00c04fd430c8-")`, "uuid.parse: invalid UUID"},
+		{`uuid.parse("6ba7b810x9dad-11d1-80b4-00c04fd430c8")`, "uuid.parse: invalid UUID"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = e.Eval(map[string]any{})
+			assert.ErrorIs(t, err, ErrFunction)
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestHashFunctionsBucketing(t *testing.T) {
+	env, err := DefaultEnv.Extend(HashFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	e, err := NewExpr(`hash.fnv64(this.user_id) % 100u < 10u`, env)
+	if !assert.NoError(t, err) {
+		return
+	}
+	got, err := e.Eval(WrapThisVariable(map[string]any{"user_id": "u-1001"}))
+	assert.NoError(t, err)
+	h := fnv.New64a()
+	h.Write([]byte("u-1001"))
+	assert.Equal(t, h.Sum64()%100 < 10, got)
+
+	// 请求中的写法不需要 WithNumericCoercion，超出 int 范围的哈希值取模时不报溢出，与 WithNumericCoercion 同时使用时结果相同
+	coerced, err := env.WithNumericCoercion()
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, env := range []*Env{env, coerced} {
+		e, err = NewExpr(`hash.fnv64(this.user_id) % 100 < 10`, env)
+		if !assert.NoError(t, err) {
+			return
+		}
+		large := 0
+		for i := 0; i < 20; i++ {
+			id := fmt.Sprintf("u-%d", i)
+			h := fnv.New64a()
+			h.Write([]byte(id))
+			if h.Sum64() > math.MaxInt64 {
+				large++
+			}
+			got, err := e.Eval(WrapThisVariable(map[string]any{"user_id": id}))
+			assert.NoError(t, err)
+			assert.Equal(t, h.Sum64()%100 < 10, got, id)
+		}
+		assert.Positive(t, large)
+	}
+
+	tests := []struct {
+		expression string
+		want       any
+		err        error
+	}{
+		{expression: `18446744073709551615u % 100`, want: int64(15)},
+		{expression: `18446744073709551615u % -100`, want: int64(15)},
+		{expression: `7u % 3`, want: int64(1)},
+		{expression: `7u % 0`, err: ErrDivisionByZero},
+	}
+	for _, tt := range tests {
+		e, err := NewExpr(tt.expression, env)
+		if !assert.NoError(t, err, tt.expression) {
+			continue
+		}
+		got, err := e.Eval(map[string]any{})
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, tt.expression)
+			continue
+		}
+		assert.NoError(t, err, tt.expression)
+		assert.Equal(t, tt.want, got, tt.expression)
+	}
+
+	// 与 EncoderExtensions 和 NetFunctions 可以同时引入
+	env, err = StandardEnv.Extend(HashFunctions(), NetFunctions())
+	if assert.NoError(t, err) {
+		e, err = NewExpr(`base64.encode(hash.md5(url(this.u).getHost()))`, env)
+		if assert.NoError(t, err) {
+			got, err = e.Eval(WrapThisVariable(map[string]any{"u": "https://a.com/x"}))
+			assert.NoError(t, err)
+			assert.NotEmpty(t, got)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter"
+)
+
+// lenientConfig 宽松模式的配置，defaults 的 key 为字段路径
+type lenientConfig struct {
+	defaults map[string]Val
+}
+
+// WithLenient 返回宽松模式的新环境：访问不存在的变量、字段或 key 时不再报错，
+// 而是返回 defaults 中对应路径的默认值，没有默认值时返回 null；
+// 路径的格式同 PartialEval，如 this.order.amount，map 的 key 也以 . 连接，如 this.tags["color"] 的路径为 this.tags.color。
+// 宽松模式下 <、<=、>、>= 任一侧为 null 时结果为 false，has() 的行为不变。
+func (e *Env) WithLenient(defaults map[string]any) *Env {
+	ext := e.clone()
+	ext.lenient = &lenientConfig{defaults: make(map[string]Val, len(defaults))}
+	adapter := e.env.CELTypeAdapter()
+	for path, v := range defaults {
+		ext.lenient.defaults[path] = adapter.NativeToValue(v)
+	}
+	return ext
+}
+
+func (c *lenientConfig) decorate(i interpreter.Interpretable) (interpreter.Interpretable, error) {
+	switch i := i.(type) {
+	case *lenientAttr:
+		return i, nil
+	case interpreter.InterpretableAttribute:
+		return &lenientAttr{InterpretableAttribute: i, config: c}, nil
+	case interpreter.InterpretableCall:
+		switch i.Function() {
+		case operators.Less, operators.LessEquals, operators.Greater, operators.GreaterEquals:
+			if len(i.Args()) == 2 {
+				return &lenientCompare{InterpretableCall: i}, nil
+			}
+		}
+	}
+	return i, nil
+}
+
+// lenientAttr 变量、字段或 key 不存在时返回默认值或 null 的取值指令。
+// 字段选择会在同一个指令上追加限定符，因此执行时的路径即完整的访问路径。
+type lenientAttr struct {
+	interpreter.InterpretableAttribute
+	config *lenientConfig
+}
+
+func (a *lenientAttr) Eval(vars interpreter.Activation) Val {
+	v := a.InterpretableAttribute.Eval(vars)
+	err, ok := v.(*types.Err)
+	if !ok {
+		return v
+	}
+	switch evalErrorKind(err) {
+	case KindMissingKey, KindMissingAttribute:
+	default:
+		return v
+	}
+	if path, ok := attributePath(a.Attr()); ok {
+		if def, found := a.config.defaults[path]; found {
+			return def
+		}
+	}
+	return types.NullValue
+}
+
+// attributePath 返回由变量和常量限定符组成的访问路径，包含动态限定符时返回 false
+func attributePath(attr interpreter.Attribute) (string, bool) {
+	ns, ok := attr.(interpreter.NamespacedAttribute)
+	if !ok || len(ns.CandidateVariableNames()) == 0 {
+		return "", false
+	}
+	var b strings.Builder
+	b.WriteString(ns.CandidateVariableNames()[0])
+	for _, q := range ns.Qualifiers() {
+		c, ok := q.(interpreter.ConstantQualifier)
+		if !ok {
+			return "", false
+		}
+		switch v := c.Value().(type) {
+		case types.String:
+			b.WriteString(".")
+			b.WriteString(string(v))
+		case types.Int:
+			b.WriteString("[" + strconv.FormatInt(int64(v), 10) + "]")
+		case types.Uint:
+			b.WriteString("[" + strconv.FormatUint(uint64(v), 10) + "]")
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// lenientCompare 任一侧为 null 时结果为 false 的比较运算
+type lenientCompare struct {
+	interpreter.InterpretableCall
+}
+
+func (c *lenientCompare) Eval(vars interpreter.Activation) Val {
+	args := c.Args()
+	lhs := args[0].Eval(vars)
+	if types.IsUnknownOrError(lhs) {
+		return lhs
+	}
+	rhs := args[1].Eval(vars)
+	if types.IsUnknownOrError(rhs) {
+		return rhs
+	}
+	if lhs == types.NullValue || rhs == types.NullValue {
+		return types.False
+	}
+	cmp, ok := lhs.(traits.Comparer)
+	if !ok {
+		return types.LabelErrNode(c.ID(), types.MaybeNoSuchOverloadErr(lhs))
+	}
+	out := cmp.Compare(rhs)
+	if types.IsError(out) {
+		return types.LabelErrNode(c.ID(), out)
+	}
+	switch c.Function() {
+	case operators.Less:
+		return types.Bool(out == types.IntNegOne)
+	case operators.LessEquals:
+		return types.Bool(out != types.IntOne)
+	case operators.Greater:
+		return types.Bool(out == types.IntOne)
+	default:
+		return types.Bool(out != types.IntNegOne)
+	}
+}
@@ -0,0 +1,64 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnv_WithLenient(t *testing.T) {
+	env, err := DefaultEnv.Extend(UseThisVariable())
+	assert.NoError(t, err)
+	lenient := env.WithLenient(map[string]any{
+		"this.level":       "normal",
+		"this.order.count": 1,
+		"this.tags.color":  "none",
+	})
+
+	tests := []struct {
+		name       string
+		expression string
+		input      map[string]any
+		want       any
+	}{
+		{"missing key is null", `this.v1 == null`, map[string]any{}, true},
+		{"missing nested key is null", `this.a.b.c == null`, map[string]any{}, true},
+		{"missing variable is null", `this.v1 == null`, nil, true},
+		{"greater than null", `this.v1 > 0`, map[string]any{}, false},
+		{"less than null", `this.v1 < 0`, map[string]any{}, false},
+		{"null on the right", `0 <= this.v1`, map[string]any{}, false},
+		{"compare present value", `this.v1 >= 1`, map[string]any{"v1": 1}, true},
+		{"explicit null", `this.v1 > 0 || this.v1 <= 0`, map[string]any{"v1": nil}, false},
+		{"default value", `this.level == "normal"`, map[string]any{}, true},
+		{"present value overrides default", `this.level`, map[string]any{"level": "vip"}, "vip"},
+		{"nested default", `this.order.count + 1`, map[string]any{"order": map[string]any{}}, int64(2)},
+		{"index default", `this.tags["color"]`, map[string]any{"tags": map[string]any{}}, "none"},
+		{"has is unchanged", `has(this.v1)`, map[string]any{}, false},
+		{"map literal index", `dyn({"a": 1})["b"] == null`, map[string]any{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, lenient)
+			assert.NoError(t, err)
+			input := map[string]any{}
+			if tt.input != nil {
+				input = WrapThisVariable(tt.input)
+			}
+			got, err := e.Eval(input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// 默认不启用宽松模式
+	e, err := NewExpr(`this.v1 > 0`, env)
+	assert.NoError(t, err)
+	_, err = e.Eval(WrapThisVariable(map[string]any{}))
+	assert.ErrorIs(t, err, ErrNoSuchKey)
+
+	// 除比较外的运算仍然会报错
+	e, err = NewExpr(`this.v1 + 1 > 0`, lenient)
+	assert.NoError(t, err)
+	_, err = e.Eval(WrapThisVariable(map[string]any{}))
+	assert.ErrorIs(t, err, ErrNoMatchingOverload)
+}
@@ -0,0 +1,55 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/cel-go/ext"
+)
+
+// NativeTypes 注册普通的 Go 结构体类型，无需定义 protobuf 即可在表达式中直接使用。
+// 结构体按 "包名.类型名" 暴露（如 model.Order），字段名优先使用 json tag，
+// 嵌套的结构体、指针、slice、map 和 time.Time 等字段类型会一并注册。
+// 如果同时使用 protobuf 类型，需要先注册 Types 再注册 NativeTypes。
+func NativeTypes(refTypes ...reflect.Type) Option {
+	args := make([]any, 0, len(refTypes)+1)
+	args = append(args, ext.ParseStructField(jsonFieldName))
+	for _, t := range refTypes {
+		args = append(args, t)
+	}
+	return ext.NativeTypes(args...)
+}
+
+// NativeObjectType 返回通过 NativeTypes 注册的 Go 结构体对应的 cel 类型，typ 可以是结构体或其指针
+func NativeObjectType(typ reflect.Type) *Type {
+	return ObjectType(nativeTypeName(typ))
+}
+
+func nativeTypeName(typ reflect.Type) string {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	pkg := typ.PkgPath()
+	if i := strings.LastIndex(pkg, "/"); i >= 0 {
+		pkg = pkg[i+1:]
+	}
+	return fmt.Sprintf("%s.%s", pkg, typ.Name())
+}
+
+// jsonFieldName 返回字段在表达式中的名称，json tag 中声明了名称时使用该名称，否则使用字段名；
+// json:"-" 的字段返回一个不合法的标识符，使其无法在表达式中访问
+func jsonFieldName(field reflect.StructField) string {
+	tag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return field.Name
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	switch name {
+	case "":
+		return field.Name
+	case "-":
+		return "-" + field.Name
+	}
+	return name
+}
@@ -0,0 +1,85 @@
+package expr
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type nativeAddress struct {
+	City string `json:"city"`
+}
+
+type nativeOrder struct {
+	ID        int64             `json:"id"`
+	Amount    float64           `json:"amount,omitempty"`
+	Tags      []string          `json:"tags"`
+	Attrs     map[string]string `json:"attrs"`
+	Address   *nativeAddress    `json:"address"`
+	Items     []nativeItem      `json:"items"`
+	CreatedAt time.Time         `json:"created_at"`
+	Remark    string
+	Secret    string `json:"-"`
+	Token     string `json:"-"`
+}
+
+type nativeItem struct {
+	SKU   string  `json:"sku"`
+	Price float64 `json:"price"`
+}
+
+func TestNativeTypes(t *testing.T) {
+	env, err := NewEnv(
+		NativeTypes(reflect.TypeOf(nativeOrder{})),
+		Variable("order", NativeObjectType(reflect.TypeOf(&nativeOrder{}))),
+	)
+	assert.NoError(t, err)
+
+	order := &nativeOrder{
+		ID:        1,
+		Amount:    99.5,
+		Tags:      []string{"vip"},
+		Attrs:     map[string]string{"channel": "app"},
+		Address:   &nativeAddress{City: "Hangzhou"},
+		Items:     []nativeItem{{SKU: "a", Price: 10}, {SKU: "b", Price: 20}},
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Remark:    "r",
+	}
+	input := map[string]any{"order": order}
+
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{expression: "order.id == 1 && order.amount > 90.0", want: true},
+		{expression: `"vip" in order.tags`, want: true},
+		{expression: `order.attrs.channel`, want: "app"},
+		{expression: `order.address.city`, want: "Hangzhou"},
+		{expression: `order.items.exists(i, i.sku == "b" && i.price > 15.0)`, want: true},
+		{expression: `order.created_at < timestamp("2024-06-01T00:00:00Z")`, want: true},
+		{expression: `order.Remark`, want: "r"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			assert.NoError(t, err)
+			got, err := e.Eval(input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err = NewExpr("order.Amount > 0.0", env)
+	assert.Error(t, err)
+	_, err = NewExpr("order.Secret", env)
+	assert.Error(t, err)
+
+	// 在表达式中构造结构体，并解析为 Go 的结构体
+	e, err := NewTypedExpr[nativeItem](`expr.nativeItem{sku: "c", price: 1.5}`, env)
+	assert.NoError(t, err)
+	got, err := e.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, nativeItem{SKU: "c", Price: 1.5}, got)
+}
@@ -0,0 +1,304 @@
+package expr
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"reflect"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+type (
+	// IP ip() 返回的 IP 地址，Value 为 netip.Addr
+	IP struct {
+		netip.Addr
+	}
+
+	// CIDR cidr() 返回的网段，Value 为 netip.Prefix
+	CIDR struct {
+		netip.Prefix
+	}
+
+	// URL url() 返回的 URL，Value 为 *url.URL
+	URL struct {
+		*url.URL
+	}
+
+	netLib struct{}
+
+	// netLiteralValidator 在类型检查时校验 ip()、cidr() 和 url() 的常量参数
+	netLiteralValidator struct{}
+)
+
+var (
+	IPType   = types.NewOpaqueType("net.IP")
+	CIDRType = types.NewOpaqueType("net.CIDR")
+	URLType  = types.NewOpaqueType("net.URL")
+
+	// netParsers 字符串转换为网络类型的函数，key 为函数名
+	netParsers = map[string]func(s string) (Val, error){
+		"ip":   parseIP,
+		"cidr": parseCIDR,
+		"url":  parseURL,
+	}
+)
+
+// NetFunctions IP、网段和 URL 函数：
+//   - ip(s)、cidr(s)、url(s)：将字符串解析为 net.IP、net.CIDR 和 net.URL 类型，常量参数在编译表达式时校验，
+//     IPv4 映射的 IPv6 地址（如 ::ffff:10.1.2.3）解析为 IPv4 地址，网段的主机位清零（如 10.1.2.3/8 即 10.0.0.0/8）；
+//   - isIP(s)、isCIDR(s)、isURL(s)：字符串能否解析为对应的类型；
+//   - ip.family()：IP 地址的版本，4 或 6，ip.isIPv4()、ip.isIPv6() 判断版本；
+//   - ip.isLoopback()、ip.isPrivate()：是否为回环地址、私有地址（RFC 1918 和 RFC 4193）；
+//   - cidr.containsIP(ip)：网段是否包含 IP 地址，ip 可以是 net.IP 或字符串；
+//   - cidr.ip()、cidr.prefixLength()：网段的起始地址和前缀长度；
+//   - url.getScheme()、url.getHost()、url.getPort()、url.getPath()、url.getQuery()：URL 的各部分，
+//     getHost 不包含端口，getQuery 返回参数名到参数值列表的 map；
+//   - string(x)：转换为字符串。
+func NetFunctions() Option {
+	return cel.Lib(netLib{})
+}
+
+func (netLib) LibraryName() string {
+	return "expr.net"
+}
+
+func (netLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		Function("ip", Overload("string_to_ip", []*Type{StringType}, IPType, UnaryBinding(netParserBinding("ip")))),
+		Function("cidr", Overload("string_to_cidr", []*Type{StringType}, CIDRType, UnaryBinding(netParserBinding("cidr")))),
+		Function("url", Overload("string_to_url", []*Type{StringType}, URLType, UnaryBinding(netParserBinding("url")))),
+		Function("isIP", Overload("isIP_string", []*Type{StringType}, BoolType, UnaryBinding(netValidBinding("ip")))),
+		Function("isCIDR", Overload("isCIDR_string", []*Type{StringType}, BoolType, UnaryBinding(netValidBinding("cidr")))),
+		Function("isURL", Overload("isURL_string", []*Type{StringType}, BoolType, UnaryBinding(netValidBinding("url")))),
+		Function("string",
+			Overload("ip_to_string", []*Type{IPType}, StringType, UnaryBinding(stringOf)),
+			Overload("cidr_to_string", []*Type{CIDRType}, StringType, UnaryBinding(stringOf)),
+			Overload("url_to_string", []*Type{URLType}, StringType, UnaryBinding(stringOf)),
+		),
+		Function("family", MemberOverload("ip_family", []*Type{IPType}, IntType, UnaryBinding(func(ip Val) Val {
+			if ip.(IP).Is4() {
+				return types.Int(4)
+			}
+			return types.Int(6)
+		}))),
+		Function("isIPv4", MemberOverload("ip_isIPv4", []*Type{IPType}, BoolType, UnaryBinding(func(ip Val) Val {
+			return types.Bool(ip.(IP).Is4())
+		}))),
+		Function("isIPv6", MemberOverload("ip_isIPv6", []*Type{IPType}, BoolType, UnaryBinding(func(ip Val) Val {
+			return types.Bool(ip.(IP).Is6())
+		}))),
+		Function("isLoopback", MemberOverload("ip_isLoopback", []*Type{IPType}, BoolType, UnaryBinding(func(ip Val) Val {
+			return types.Bool(ip.(IP).IsLoopback())
+		}))),
+		Function("isPrivate", MemberOverload("ip_isPrivate", []*Type{IPType}, BoolType, UnaryBinding(func(ip Val) Val {
+			return types.Bool(ip.(IP).IsPrivate())
+		}))),
+		Function("containsIP",
+			MemberOverload("cidr_containsIP_ip", []*Type{CIDRType, IPType}, BoolType, BinaryBinding(func(cidr, ip Val) Val {
+				return types.Bool(cidr.(CIDR).Contains(ip.(IP).Unmap()))
+			})),
+			MemberOverload("cidr_containsIP_string", []*Type{CIDRType, StringType}, BoolType, BinaryBinding(func(cidr, s Val) Val {
+				ip, err := parseIP(string(s.(types.String)))
+				if err != nil {
+					return types.WrapErr(err)
+				}
+				return types.Bool(cidr.(CIDR).Contains(ip.(IP).Addr))
+			})),
+		),
+		Function("ip", MemberOverload("cidr_ip", []*Type{CIDRType}, IPType, UnaryBinding(func(cidr Val) Val {
+			return IP{Addr: cidr.(CIDR).Addr()}
+		}))),
+		Function("prefixLength", MemberOverload("cidr_prefixLength", []*Type{CIDRType}, IntType, UnaryBinding(func(cidr Val) Val {
+			return types.Int(cidr.(CIDR).Bits())
+		}))),
+		Function("getScheme", MemberOverload("url_getScheme", []*Type{URLType}, StringType, UnaryBinding(func(u Val) Val {
+			return types.String(u.(URL).Scheme)
+		}))),
+		Function("getHost", MemberOverload("url_getHost", []*Type{URLType}, StringType, UnaryBinding(func(u Val) Val {
+			return types.String(u.(URL).Hostname())
+		}))),
+		Function("getPort", MemberOverload("url_getPort", []*Type{URLType}, StringType, UnaryBinding(func(u Val) Val {
+			return types.String(u.(URL).Port())
+		}))),
+		Function("getPath", MemberOverload("url_getPath", []*Type{URLType}, StringType, UnaryBinding(func(u Val) Val {
+			return types.String(u.(URL).Path)
+		}))),
+		Function("getQuery", MemberOverload("url_getQuery", []*Type{URLType}, MapType(StringType, ListType(StringType)),
+			UnaryBinding(func(u Val) Val {
+				return types.DefaultTypeAdapter.NativeToValue(map[string][]string(u.(URL).Query()))
+			}))),
+		cel.ASTValidators(netLiteralValidator{}),
+	}
+}
+
+func (netLib) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+func (netLiteralValidator) Name() string {
+	return "expr.net.validator"
+}
+
+// Validate 校验 ip()、cidr() 和 url() 的常量参数，错误位置指向参数
+func (netLiteralValidator) Validate(_ *cel.Env, _ cel.ValidatorConfig, a *ast.AST, iss *cel.Issues) {
+	calls := ast.MatchDescendants(ast.NavigateAST(a), func(e ast.NavigableExpr) bool {
+		if e.Kind() != ast.CallKind || e.AsCall().IsMemberFunction() || len(e.AsCall().Args()) != 1 {
+			return false
+		}
+		_, found := netParsers[e.AsCall().FunctionName()]
+		return found
+	})
+	for _, call := range calls {
+		arg := call.AsCall().Args()[0]
+		if arg.Kind() != ast.LiteralKind {
+			continue
+		}
+		s, ok := arg.AsLiteral().(types.String)
+		if !ok {
+			continue
+		}
+		fn := call.AsCall().FunctionName()
+		if _, err := netParsers[fn](string(s)); err != nil {
+			iss.ReportErrorAtID(arg.ID(), "invalid literal '%s' for %s(): %v", s, fn, err)
+		}
+	}
+}
+
+func netParserBinding(fn string) UnaryOp {
+	return func(s Val) Val {
+		v, err := netParsers[fn](string(s.(types.String)))
+		if err != nil {
+			return types.WrapErr(err)
+		}
+		return v
+	}
+}
+
+func netValidBinding(fn string) UnaryOp {
+	return func(s Val) Val {
+		_, err := netParsers[fn](string(s.(types.String)))
+		return types.Bool(err == nil)
+	}
+}
+
+func stringOf(v Val) Val {
+	return types.String(fmt.Sprint(v.Value()))
+}
+
+func parseIP(s string) (Val, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return nil, err
+	}
+	if addr.Zone() != "" {
+		return nil, fmt.Errorf("ip %q: zone is not supported", s)
+	}
+	return IP{Addr: addr.Unmap()}, nil
+}
+
+func parseCIDR(s string) (Val, error) {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return nil, err
+	}
+	return CIDR{Prefix: prefix.Masked()}, nil
+}
+
+func parseURL(s string) (Val, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return URL{URL: u}, nil
+}
+
+func (ip IP) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	return convertNetToNative(ip, typeDesc)
+}
+
+func (ip IP) ConvertToType(typeVal ref.Type) Val {
+	return convertNetToType(ip, typeVal)
+}
+
+func (ip IP) Equal(other Val) Val {
+	o, ok := other.(IP)
+	return types.Bool(ok && ip.Addr == o.Addr)
+}
+
+func (ip IP) Type() ref.Type {
+	return IPType
+}
+
+func (ip IP) Value() any {
+	return ip.Addr
+}
+
+func (cidr CIDR) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	return convertNetToNative(cidr, typeDesc)
+}
+
+func (cidr CIDR) ConvertToType(typeVal ref.Type) Val {
+	return convertNetToType(cidr, typeVal)
+}
+
+func (cidr CIDR) Equal(other Val) Val {
+	o, ok := other.(CIDR)
+	return types.Bool(ok && cidr.Prefix == o.Prefix)
+}
+
+func (cidr CIDR) Type() ref.Type {
+	return CIDRType
+}
+
+func (cidr CIDR) Value() any {
+	return cidr.Prefix
+}
+
+func (u URL) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	return convertNetToNative(u, typeDesc)
+}
+
+func (u URL) ConvertToType(typeVal ref.Type) Val {
+	return convertNetToType(u, typeVal)
+}
+
+func (u URL) Equal(other Val) Val {
+	o, ok := other.(URL)
+	return types.Bool(ok && u.String() == o.String())
+}
+
+func (u URL) Type() ref.Type {
+	return URLType
+}
+
+func (u URL) Value() any {
+	return u.URL
+}
+
+// convertNetToNative 将网络类型转换为其 Value 的类型或字符串
+func convertNetToNative(v Val, typeDesc reflect.Type) (any, error) {
+	native := reflect.ValueOf(v.Value())
+	switch {
+	case native.Type().AssignableTo(typeDesc):
+		return native.Interface(), nil
+	case typeDesc.Kind() == reflect.String:
+		return reflect.ValueOf(fmt.Sprint(v.Value())).Convert(typeDesc).Interface(), nil
+	}
+	return nil, fmt.Errorf("type conversion error from '%s' to '%v'", v.Type().TypeName(), typeDesc)
+}
+
+func convertNetToType(v Val, typeVal ref.Type) Val {
+	switch typeVal {
+	case v.Type():
+		return v
+	case types.StringType:
+		return stringOf(v)
+	case types.TypeType:
+		return v.Type().(*types.Type)
+	}
+	return types.NewErr("type conversion error from '%s' to '%s'", v.Type().TypeName(), typeVal.TypeName())
+}
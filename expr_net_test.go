@@ -0,0 +1,117 @@
+package expr
+
+import (
+	"errors"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetFunctions(t *testing.T) {
+	env, err := DefaultEnv.Extend(NetFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	input := WrapThisVariable(map[string]any{
+		"ip":  "10.1.2.3",
+		"url": "https://example.com:8443/api/orders?id=1&id=2&q=x",
+	})
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{`ip(this.ip)`, netip.MustParseAddr("10.1.2.3")},
+		{`ip(this.ip) == ip("10.1.2.3")`, true},
+		{`ip(this.ip) != ip("10.1.2.4")`, true},
+		{`ip(this.ip).family()`, int64(4)},
+		{`ip("::1").family()`, int64(6)},
+		{`ip(this.ip).isIPv4() && !ip(this.ip).isIPv6()`, true},
+		{`ip("::1").isLoopback()`, true},
+		{`ip(this.ip).isPrivate()`, true},
+		{`ip("8.8.8.8").isPrivate()`, false},
+		{`string(ip("2001:db8::1"))`, "2001:db8::1"},
+		{`cidr("10.0.0.0/8").containsIP(ip(this.ip))`, true},
+		{`cidr("10.0.0.0/8").containsIP("192.168.0.1")`, false},
+		{`cidr("10.0.0.0/8").containsIP(ip("::ffff:10.1.2.3"))`, true},
+		{`cidr("10.0.0.0/8").containsIP("::ffff:10.1.2.3")`, true},
+		{`cidr("192.168.0.0/16").containsIP("::ffff:10.1.2.3")`, false},
+		{`ip("::ffff:10.1.2.3") == ip("10.1.2.3")`, true},
+		{`ip("::ffff:10.1.2.3").family()`, int64(4)},
+		{`cidr("10.1.2.3/8") == cidr("10.0.0.0/8")`, true},
+		{`string(cidr("10.1.2.3/8"))`, "10.0.0.0/8"},
+		{`cidr("10.1.2.3/8").ip() == ip("10.0.0.0")`, true},
+		{`cidr("10.0.0.0/8").ip() == ip("10.0.0.0")`, true},
+		{`cidr("2001:db8::/32").prefixLength()`, int64(32)},
+		{`string(cidr("10.0.0.0/8"))`, "10.0.0.0/8"},
+		{`isIP("1.2.3.4") && !isIP("1.2.3")`, true},
+		{`isCIDR("10.0.0.0/8") && !isCIDR("10.0.0.0")`, true},
+		{`isURL("https://example.com") && !isURL("http://[::1")`, true},
+		{`url(this.url).getScheme()`, "https"},
+		{`url(this.url).getHost()`, "example.com"},
+		{`url(this.url).getPort()`, "8443"},
+		{`url(this.url).getPath()`, "/api/orders"},
+		{`url(this.url).getQuery()`, map[string][]string{"id": {"1", "2"}, "q": {"x"}}},
+		{`url(this.url).getQuery().id[1]`, "2"},
+		{`string(url("/a?b=1"))`, "/a?b=1"},
+		{`type(ip(this.ip)) == type(ip("::1"))`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(input)
+			assert.NoError(t, err)
+			if want, ok := tt.want.(map[string][]string); ok {
+				got, err = e.WithDecoder(NativeDecoder(reflect.TypeOf(want))).Eval(input)
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	e, err := NewExpr(`url(this.url)`, env)
+	if assert.NoError(t, err) {
+		got, err := e.Eval(input)
+		assert.NoError(t, err)
+		assert.IsType(t, &url.URL{}, got)
+	}
+}
+
+func TestNetFunctionsInvalidLiteral(t *testing.T) {
+	env, err := DefaultEnv.Extend(NetFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	tests := []struct {
+		expression string
+		column     int
+		arg        string
+	}{
+		{`ip("1.2.3")`, 3, "1.2.3"},
+		{`cidr("10.0.0.0") == cidr("10.0.0.0/8")`, 5, "10.0.0.0"},
+		{`this.a == url("http://[::1")`, 14, "http://[::1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := NewExpr(tt.expression, env)
+			var compileErr *CompileError
+			if assert.True(t, errors.As(err, &compileErr)) && assert.Len(t, compileErr.Issues, 1) {
+				issue := compileErr.Issues[0]
+				assert.Equal(t, CodeInvalidLiteral, issue.Code)
+				assert.Equal(t, tt.column, issue.Column)
+				assert.Equal(t, tt.arg, issue.Args[0])
+			}
+		})
+	}
+
+	e, err := NewExpr(`ip(this.ip)`, env)
+	if assert.NoError(t, err) {
+		_, err = e.Eval(WrapThisVariable(map[string]any{"ip": "1.2.3"}))
+		assert.ErrorIs(t, err, ErrFunction)
+	}
+}
@@ -0,0 +1,424 @@
+package expr
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/decls"
+	"github.com/google/cel-go/common/functions"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/interpreter"
+)
+
+// coerceOverloadSuffix 数值类型自动转换生成的重载 ID 后缀
+const coerceOverloadSuffix = "@coerce"
+
+// stdFunctionNames 返回 CEL 标准库的函数名，标准库函数的参数不做自动转换
+var stdFunctionNames = sync.OnceValue(func() map[string]bool {
+	names := map[string]bool{}
+	if env, err := cel.NewEnv(); err == nil {
+		for name := range env.Functions() {
+			names[name] = true
+		}
+	}
+	return names
+})
+
+// WithNumericCoercion 返回自动转换数值类型的新环境，int、uint 和 double 可以混合参与运算：
+//   - 比较运算（==、!=、<、<=、>、>=）按数值大小比较；
+//   - 算术运算（+、-、*、/）中 int 或 uint 与 double 混合时转换为 double，int 与 uint 混合时转换为 int，% 仅支持 int 与 uint 混合，
+//     超出 int 范围的 uint 取模时按数值计算而不报溢出，如 hash.fnv64(this.user_id) % 100；
+//   - 标准库以外的函数（自定义函数和 cel-go 扩展库函数，如 slice、substring）的数值参数可以传入其他数值类型：
+//     double 参数可以传入 int 或 uint，int 和 uint 参数可以互相传入，调用时转换为参数的类型，超出范围时报错，
+//     接收 context.Context 的 GoFunction 经转换调用时同样传入 EvalContext 的 ctx。
+//
+// 之后通过 Extend 声明的函数同样支持参数的自动转换，已处理过的函数不会重复处理。
+func (e *Env) WithNumericCoercion() (*Env, error) {
+	opts := []Option{cel.CrossTypeNumericComparisons(true)}
+	numbers := []*Type{IntType, UintType, DoubleType}
+	for _, lhs := range numbers {
+		for _, rhs := range numbers {
+			if lhs == rhs {
+				continue
+			}
+			args := []*Type{lhs, rhs}
+			id := func(op string) string {
+				return fmt.Sprintf("%s_%s_%s%s", op, lhs, rhs, coerceOverloadSuffix)
+			}
+			result := promotedType(lhs, rhs)
+			for op, name := range map[string]string{
+				operators.Add:      "add",
+				operators.Subtract: "subtract",
+				operators.Multiply: "multiply",
+				operators.Divide:   "divide",
+			} {
+				opts = append(opts, Function(op, Overload(id(name), args, result)))
+			}
+			if result == IntType {
+				opts = append(opts, Function(operators.Modulo, Overload(id("modulo"), args, IntType)))
+			}
+		}
+	}
+	env, err := e.env.Extend(opts...)
+	if err != nil {
+		return nil, err
+	}
+	if env, err = coerceFunctionArgs(env, nil); err != nil {
+		return nil, err
+	}
+	ext := e.clone()
+	ext.env = env
+	ext.numericCoercion = true
+	return ext, nil
+}
+
+// equalityMismatch 匹配比较不同数值类型的 == 和 != 的类型检查错误
+var equalityMismatch = regexp.MustCompile(`^found no matching overload for '_[!=]=_' applied to '\((int|uint|double), (int|uint|double)\)'$`)
+
+// coerceEquality 将比较不同数值类型的 == 和 != 的参数包装为 dyn()，返回是否修改了语法树。
+// == 和 != 的重载是泛型的 (A, A)，无法声明混合数值类型的重载，因此改写语法树后重新检查。
+func coerceEquality(parsed *cel.Ast, issues *cel.Issues) bool {
+	native := parsed.NativeRep()
+	root, info := native.Expr(), native.SourceInfo()
+	var nextID int64
+	visitor := ast.NewExprVisitor(func(e ast.Expr) {
+		nextID = max(nextID, e.ID())
+	})
+	ast.PostOrderVisit(root, visitor)
+	for id, call := range info.MacroCalls() {
+		nextID = max(nextID, id)
+		ast.PostOrderVisit(call, visitor)
+	}
+
+	fac := ast.NewExprFactory()
+	changed := false
+	for _, err := range issues.Errors() {
+		if !equalityMismatch.MatchString(err.Message) {
+			continue
+		}
+		call := findExpr(root, err.ExprID)
+		if call == nil || call.Kind() != ast.CallKind {
+			continue
+		}
+		for _, arg := range call.AsCall().Args() {
+			argID := arg.ID()
+			nextID++
+			inner := fac.CopyExpr(arg)
+			inner.RenumberIDs(func(id int64) int64 {
+				if id == argID {
+					return nextID
+				}
+				return id
+			})
+			if r, found := info.GetOffsetRange(argID); found {
+				info.SetOffsetRange(nextID, r)
+			}
+			if m, found := info.GetMacroCall(argID); found {
+				info.ClearMacroCall(argID)
+				info.SetMacroCall(nextID, m)
+			}
+			arg.SetKindCase(fac.NewCall(argID, overloads.TypeConvertDyn, inner))
+		}
+		changed = true
+	}
+	return changed
+}
+
+// promotedType 返回两种不同的数值类型混合运算时的结果类型
+func promotedType(lhs, rhs *Type) *Type {
+	if lhs == DoubleType || rhs == DoubleType {
+		return DoubleType
+	}
+	return IntType
+}
+
+// coerceFunctionArgs 为标准库以外的函数中含有数值参数的重载生成接受其他数值类型的重载，调用时将参数转换为原类型。
+// prev 为 Extend 前的环境，重载与 prev 中相同的函数已经处理过，不再重复处理
+func coerceFunctionArgs(env, prev *cel.Env) (*cel.Env, error) {
+	var opts []Option
+	for name, fn := range env.Functions() {
+		if stdFunctionNames()[name] {
+			continue
+		}
+		if prev != nil {
+			if prevFn, ok := prev.Functions()[name]; ok && len(prevFn.OverloadDecls()) == len(fn.OverloadDecls()) {
+				continue
+			}
+		}
+		bindings, err := fn.Bindings()
+		if err != nil || len(bindings) == 0 || bindings[0].Operator == name {
+			// 没有绑定或者使用单例绑定的函数不做转换
+			continue
+		}
+		impls := make(map[string]*functions.Overload, len(bindings))
+		for _, b := range bindings {
+			impls[b.Operator] = b
+		}
+		// 同一参数组合可由多个重载转换得到时，选择需要转换的参数最少的重载，其次选择 int 与 uint 之间转换最少的重载，
+		// 这类转换可能在执行时超出范围
+		type candidate struct {
+			origin      *decls.OverloadDecl
+			impl        *functions.Overload
+			args        []*Type
+			conversions int
+			intUint     int
+		}
+		var candidates []candidate
+		existing := fn.OverloadDecls()
+		for _, o := range existing {
+			impl, ok := impls[o.ID()]
+			if !ok || strings.HasSuffix(o.ID(), coerceOverloadSuffix) {
+				continue
+			}
+			for _, args := range coercedArgTypes(o.ArgTypes()) {
+				c := candidate{origin: o, impl: impl, args: args}
+				for i, t := range args {
+					if param := o.ArgTypes()[i]; t != param {
+						c.conversions++
+						if param != DoubleType {
+							c.intUint++
+						}
+					}
+				}
+				candidates = append(candidates, c)
+			}
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			if candidates[i].conversions != candidates[j].conversions {
+				return candidates[i].conversions < candidates[j].conversions
+			}
+			return candidates[i].intUint < candidates[j].intUint
+		})
+		var declared []signature
+		for _, o := range existing {
+			declared = append(declared, signature{args: o.ArgTypes(), member: o.IsMemberFunction()})
+		}
+		for _, c := range candidates {
+			o := c.origin
+			sig := signature{args: c.args, member: o.IsMemberFunction()}
+			if slices.ContainsFunc(declared, sig.equals) {
+				continue
+			}
+			id := o.ID()
+			for _, t := range c.args {
+				id += "_" + t.String()
+			}
+			id += coerceOverloadSuffix
+			binding := FunctionBinding(coercedBinding(c.impl, o.ArgTypes()))
+			var opt cel.FunctionOpt
+			if o.IsMemberFunction() {
+				opt = MemberOverload(id, c.args, o.ResultType(), binding)
+			} else {
+				opt = Overload(id, c.args, o.ResultType(), binding)
+			}
+			opts = append(opts, Function(name, opt))
+			declared = append(declared, sig)
+		}
+	}
+	if len(opts) == 0 {
+		return env, nil
+	}
+	return env.Extend(opts...)
+}
+
+// coercedArgTypes 返回将 double 参数替换为 int 或 uint、int 和 uint 参数互相替换的所有参数组合，不包含原参数
+func coercedArgTypes(args []*Type) [][]*Type {
+	combos := [][]*Type{nil}
+	for _, t := range args {
+		alternatives := []*Type{t}
+		switch t {
+		case DoubleType:
+			alternatives = []*Type{DoubleType, IntType, UintType}
+		case IntType:
+			alternatives = []*Type{IntType, UintType}
+		case UintType:
+			alternatives = []*Type{UintType, IntType}
+		}
+		var next [][]*Type
+		for _, c := range combos {
+			for _, alt := range alternatives {
+				next = append(next, append(append([]*Type{}, c...), alt))
+			}
+		}
+		combos = next
+	}
+	return combos[1:]
+}
+
+// signature 重载的参数类型
+type signature struct {
+	args   []*Type
+	member bool
+}
+
+func (s signature) equals(other signature) bool {
+	return s.member == other.member && slices.EqualFunc(s.args, other.args, (*Type).IsExactType)
+}
+
+// coercedBinding 将数值参数转换为原重载的参数类型后调用原重载
+func coercedBinding(impl *functions.Overload, params []*Type) FunctionOp {
+	return func(args ...Val) Val {
+		converted := make([]Val, len(args))
+		for i, arg := range args {
+			converted[i] = arg
+			if t := numericType(arg); t != nil && isNumericType(params[i]) && !t.IsExactType(params[i]) {
+				if converted[i] = arg.ConvertToType(params[i]); types.IsError(converted[i]) {
+					return converted[i]
+				}
+			}
+		}
+		switch {
+		case len(converted) == 1 && impl.Unary != nil:
+			return impl.Unary(converted[0])
+		case len(converted) == 2 && impl.Binary != nil:
+			return impl.Binary(converted[0], converted[1])
+		case impl.Function != nil:
+			return impl.Function(converted...)
+		}
+		return types.NoSuchOverloadErr()
+	}
+}
+
+// numericCall 在执行时转换算术运算中混合的数值类型
+type numericCall struct {
+	interpreter.InterpretableCall
+}
+
+func decorateNumeric(i interpreter.Interpretable) (interpreter.Interpretable, error) {
+	if c, ok := i.(*contextCall); ok && c.lib != nil {
+		// 执行时才选择重载的 GoFunction 调用同样允许转换数值参数
+		c.coerce = true
+		return c, nil
+	}
+	call, ok := i.(interpreter.InterpretableCall)
+	if !ok || len(call.Args()) != 2 {
+		return i, nil
+	}
+	switch call.Function() {
+	case operators.Add, operators.Subtract, operators.Multiply, operators.Divide, operators.Modulo:
+		return &numericCall{InterpretableCall: call}, nil
+	}
+	return i, nil
+}
+
+func (c *numericCall) Eval(vars interpreter.Activation) Val {
+	args := c.Args()
+	lhs := args[0].Eval(vars)
+	if types.IsUnknownOrError(lhs) {
+		return lhs
+	}
+	rhs := args[1].Eval(vars)
+	if types.IsUnknownOrError(rhs) {
+		return rhs
+	}
+	if c.Function() == operators.Modulo {
+		if out, ok := moduloMixed(lhs, rhs); ok {
+			return types.LabelErrNode(c.ID(), out)
+		}
+	}
+	lhs, rhs = promoteNumbers(lhs, rhs)
+	if types.IsError(lhs) {
+		return types.LabelErrNode(c.ID(), lhs)
+	}
+	if types.IsError(rhs) {
+		return types.LabelErrNode(c.ID(), rhs)
+	}
+	var out Val
+	switch c.Function() {
+	case operators.Add:
+		if l, ok := lhs.(traits.Adder); ok {
+			out = l.Add(rhs)
+		}
+	case operators.Subtract:
+		if l, ok := lhs.(traits.Subtractor); ok {
+			out = l.Subtract(rhs)
+		}
+	case operators.Multiply:
+		if l, ok := lhs.(traits.Multiplier); ok {
+			out = l.Multiply(rhs)
+		}
+	case operators.Divide:
+		if l, ok := lhs.(traits.Divider); ok {
+			out = l.Divide(rhs)
+		}
+	case operators.Modulo:
+		if l, ok := lhs.(traits.Modder); ok {
+			out = l.Modulo(rhs)
+		}
+	}
+	if out == nil {
+		out = types.MaybeNoSuchOverloadErr(lhs)
+	}
+	return types.LabelErrNode(c.ID(), out)
+}
+
+// moduloMixed 计算 uint 超出 int 范围时 int 与 uint 混合的取模，结果总在 int 范围内；
+// 其他情况返回 false，按转换为 int 后的取模计算
+func moduloMixed(lhs, rhs Val) (Val, bool) {
+	switch l := lhs.(type) {
+	case types.Uint:
+		r, ok := rhs.(types.Int)
+		if !ok || l <= math.MaxInt64 {
+			return nil, false
+		}
+		if r == 0 {
+			return types.IntOne.Modulo(r), true
+		}
+		// -r 在 r 为 math.MinInt64 时溢出，按 -(r+1)+1 计算绝对值
+		abs := uint64(r)
+		if r < 0 {
+			abs = uint64(-(r + 1)) + 1
+		}
+		return types.Int(uint64(l) % abs), true
+	case types.Int:
+		r, ok := rhs.(types.Uint)
+		if !ok || r <= math.MaxInt64 {
+			return nil, false
+		}
+		// 除数大于被除数的绝对值，仅 math.MinInt64 % 2^63 为 0
+		if l == math.MinInt64 && uint64(r) == 1<<63 {
+			return types.IntZero, true
+		}
+		return l, true
+	}
+	return nil, false
+}
+
+// promoteNumbers 将两个不同类型的数值转换为同一类型，非数值或类型相同时原样返回
+func promoteNumbers(lhs, rhs Val) (Val, Val) {
+	lt, rt := numericType(lhs), numericType(rhs)
+	if lt == nil || rt == nil || lt == rt {
+		return lhs, rhs
+	}
+	target := types.IntType
+	if lt == types.DoubleType || rt == types.DoubleType {
+		target = types.DoubleType
+	}
+	return lhs.ConvertToType(target), rhs.ConvertToType(target)
+}
+
+func isNumericType(t *Type) bool {
+	return t.IsExactType(IntType) || t.IsExactType(UintType) || t.IsExactType(DoubleType)
+}
+
+func numericType(v Val) *Type {
+	switch v.(type) {
+	case types.Int:
+		return types.IntType
+	case types.Uint:
+		return types.UintType
+	case types.Double:
+		return types.DoubleType
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnv_WithNumericCoercion(t *testing.T) {
+	env, err := DefaultEnv.Extend(UseThisVariable())
+	assert.NoError(t, err)
+	env, err = env.WithNumericCoercion()
+	assert.NoError(t, err)
+
+	tests := []struct {
+		expression string
+		input      map[string]any
+		want       any
+		wantErr    string
+	}{
+		{expression: `1.0 < 2`, want: true},
+		{expression: `2u >= 1.5`, want: true},
+		{expression: `1 == 1.0`, want: true},
+		{expression: `1u != 2`, want: true},
+		{expression: `1 + 0.5`, want: 1.5},
+		{expression: `0.5 * 2u`, want: 1.0},
+		{expression: `3 / 2.0`, want: 1.5},
+		{expression: `5u - 7`, want: int64(-2)},
+		{expression: `7 % 4u`, want: int64(3)},
+		{expression: `18446744073709551615u % 100`, want: int64(15)},
+		{expression: `18446744073709551615u % -100`, want: int64(15)},
+		{expression: `-7 % 18446744073709551615u`, want: int64(-7)},
+		{expression: `1 + 2`, want: int64(3)},
+		{expression: `"a" + "b"`, want: "ab"},
+		{expression: `this.a + this.b`, input: map[string]any{"a": 1, "b": 0.5}, want: 1.5},
+		{expression: `this.a * 2.0 > this.b`, input: map[string]any{"a": 1, "b": 1}, want: true},
+		{expression: `this.a + 1`, input: map[string]any{"a": uint64(math.MaxUint64)}, wantErr: "integer overflow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(WrapThisVariable(tt.input))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// 默认不自动转换
+	_, err = NewExpr(`1.0 < 2`)
+	assert.Error(t, err)
+}
+
+func TestEnv_WithNumericCoercion_Function(t *testing.T) {
+	half := Function("half",
+		Overload("half_double", []*Type{DoubleType}, DoubleType,
+			UnaryBinding(func(v Val) Val { return v.(Double) / 2 })),
+	)
+	env, err := DefaultEnv.Extend(half)
+	assert.NoError(t, err)
+	env, err = env.WithNumericCoercion()
+	assert.NoError(t, err)
+
+	// 之后声明的函数同样支持参数的自动转换
+	env, err = env.Extend(UseThisVariable(), Function("scale",
+		MemberOverload("double_scale_double", []*Type{DoubleType, DoubleType}, DoubleType,
+			BinaryBinding(func(lhs, rhs Val) Val { return lhs.(Double) * rhs.(Double) })),
+		MemberOverload("double_scale_int", []*Type{DoubleType, IntType}, DoubleType,
+			BinaryBinding(func(lhs, rhs Val) Val { return lhs.(Double) * Double(rhs.(Int)) * 10 })),
+	))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{`half(3)`, 1.5},
+		{`half(3u)`, 1.5},
+		{`half(3.0)`, 1.5},
+		{`2.scale(1.5)`, 3.0},
+		{`2u.scale(2u)`, 4.0},
+		// 已声明的重载优先
+		{`2.0.scale(2)`, 40.0},
+		{`half(this.v)`, 2.0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(WrapThisVariable(map[string]any{"v": 4}))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestEnv_WithNumericCoercion_Extensions(t *testing.T) {
+	env, err := StandardEnv.WithNumericCoercion()
+	if !assert.NoError(t, err) {
+		return
+	}
+	tests := []struct {
+		expression string
+		want       any
+		wantErr    string
+	}{
+		{expression: `[1, 2].slice(0, 1u)`, want: []any{int64(1)}},
+		{expression: `"hello".substring(1u, 3)`, want: "el"},
+		{expression: `"hello".charAt(1u)`, want: "e"},
+		{expression: `math.round(2)`, want: 2.0},
+		{expression: `"hello".charAt(18446744073709551615u)`, wantErr: "integer overflow"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(map[string]any{})
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// Extend 时不会重复处理已经处理过的函数
+	ext, err := env.Extend(Variable("n", IntType))
+	if assert.NoError(t, err) {
+		assert.Len(t, ext.env.Functions()["slice"].OverloadDecls(), len(env.env.Functions()["slice"].OverloadDecls()))
+	}
+}
+
+func TestEnv_WithNumericCoercion_GoFunctionContext(t *testing.T) {
+	env, err := NewEnv(UseThisVariable(), GoFunction("scaled", func(ctx context.Context, x float64) string {
+		v, _ := ctx.Value(gofuncKey{}).(string)
+		return fmt.Sprintf("%s:%g", v, x)
+	}))
+	assert.NoError(t, err)
+	env, err = env.WithNumericCoercion()
+	assert.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), gofuncKey{}, "req-4")
+	input := WrapThisVariable(map[string]any{"i": 2, "u": uint(3), "d": 1.5})
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{expression: `scaled(1.0)`, want: "req-4:1"},
+		{expression: `scaled(1)`, want: "req-4:1"},
+		{expression: `scaled(1u)`, want: "req-4:1"},
+		// 自动转换增加重载后，dyn 参数的调用在执行时选择重载
+		{expression: `scaled(this.i)`, want: "req-4:2"},
+		{expression: `scaled(this.u)`, want: "req-4:3"},
+		{expression: `scaled(this.d)`, want: "req-4:1.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.EvalContext(ctx, input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+)
+
+// PartialResult 部分求值的结果，输入足以确定结果时 Residual 为 nil，Value 为表达式的值；
+// 否则 Residual 为仅包含未确定部分的表达式，补齐输入后可继续求值
+type PartialResult struct {
+	Value    any
+	Residual *Expr
+}
+
+// Known 返回表达式的结果是否已经确定
+func (r *PartialResult) Known() bool {
+	return r.Residual == nil
+}
+
+// PartialEval 在部分输入未知的情况下执行表达式。unknowns 为未知的字段路径，
+// 如 "this.order"、"this.items[0]"，"*" 匹配任意字段，如 "this.*.price"
+func (e *Expr) PartialEval(input map[string]any, unknowns ...string) (*PartialResult, error) {
+	patterns := make([]*interpreter.AttributePattern, len(unknowns))
+	for i, u := range unknowns {
+		pattern, err := parseAttributePattern(u)
+		if err != nil {
+			return nil, err
+		}
+		patterns[i] = pattern
+	}
+	vars, err := cel.PartialVars(input, patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := e.partial.get(e, cel.EvalOptions(cel.OptPartialEval, cel.OptTrackState))
+	if err != nil {
+		return nil, err
+	}
+	ev, det, err := p.Eval(vars)
+	if err != nil {
+		return nil, e.evalErr(err, det)
+	}
+	if !types.IsUnknown(ev) {
+		v, err := e.decode(ev)
+		if err != nil {
+			return nil, err
+		}
+		return &PartialResult{Value: v}, nil
+	}
+
+	ast, err := e.env.env.ResidualAst(e.ast, det)
+	if err != nil {
+		return nil, fmt.Errorf("residual expression: %w", err)
+	}
+	residual, err := newExpr(e.env, ast)
+	if err != nil {
+		return nil, err
+	}
+	residual.decoder = e.decoder
+	return &PartialResult{Residual: residual}, nil
+}
+
+// parseAttributePattern 解析以 "." 分隔的字段路径，支持 "*" 通配符和 [n] 形式的整数下标
+func parseAttributePattern(path string) (*interpreter.AttributePattern, error) {
+	var pattern *interpreter.AttributePattern
+	for i, seg := range strings.Split(path, ".") {
+		name, indexes, _ := strings.Cut(seg, "[")
+		switch {
+		case name == "" || i == 0 && name == "*":
+			return nil, fmt.Errorf("invalid unknown attribute: %q", path)
+		case i == 0:
+			pattern = cel.AttributePattern(name)
+		case name == "*":
+			pattern = pattern.Wildcard()
+		default:
+			pattern = pattern.QualString(name)
+		}
+		if indexes == "" {
+			continue
+		}
+		for _, idx := range strings.Split(strings.TrimSuffix(indexes, "]"), "][") {
+			if idx == "*" {
+				pattern = pattern.Wildcard()
+				continue
+			}
+			n, err := strconv.ParseInt(idx, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid unknown attribute: %q", path)
+			}
+			pattern = pattern.QualInt(n)
+		}
+	}
+	return pattern, nil
+}
@@ -0,0 +1,75 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpr_PartialEval(t *testing.T) {
+	e, err := NewExpr(`this.user.level == "vip" && this.order.amount > 100`)
+	assert.NoError(t, err)
+
+	// 已知部分足以确定结果
+	got, err := e.PartialEval(map[string]any{"this": map[string]any{
+		"user": map[string]any{"level": "normal"},
+	}}, "this.order")
+	assert.NoError(t, err)
+	assert.True(t, got.Known())
+	assert.Equal(t, false, got.Value)
+
+	// 已知部分不足以确定结果，返回剩余的表达式
+	got, err = e.PartialEval(map[string]any{"this": map[string]any{
+		"user": map[string]any{"level": "vip"},
+	}}, "this.order")
+	assert.NoError(t, err)
+	assert.False(t, got.Known())
+	assert.Equal(t, "this.order.amount > 100", got.Residual.String())
+
+	v, err := got.Residual.Eval(map[string]any{"this": map[string]any{
+		"order": map[string]any{"amount": 200},
+	}})
+	assert.NoError(t, err)
+	assert.Equal(t, true, v)
+
+	// 全部输入已知
+	got, err = e.PartialEval(map[string]any{"this": map[string]any{
+		"user":  map[string]any{"level": "vip"},
+		"order": map[string]any{"amount": 200},
+	}})
+	assert.NoError(t, err)
+	assert.True(t, got.Known())
+	assert.Equal(t, true, got.Value)
+
+	// 未标记为未知的字段缺失时仍然报错
+	_, err = e.PartialEval(map[string]any{"this": map[string]any{
+		"user": map[string]any{"level": "vip"},
+	}})
+	assert.EqualError(t, err, "no such key: order")
+}
+
+func TestExpr_PartialEval_Pattern(t *testing.T) {
+	e, err := NewExpr(`this.items[0].price > 10 || this.items[1].price > 10`)
+	assert.NoError(t, err)
+	input := map[string]any{"this": map[string]any{"items": []any{
+		map[string]any{"price": 1},
+		map[string]any{"price": 2},
+	}}}
+
+	got, err := e.PartialEval(input, "this.items[1]")
+	assert.NoError(t, err)
+	assert.Equal(t, "this.items[1].price > 10", got.Residual.String())
+
+	got, err = e.PartialEval(input, "this.items[*].price")
+	assert.NoError(t, err)
+	assert.False(t, got.Known())
+
+	got, err = e.PartialEval(input, "this.other")
+	assert.NoError(t, err)
+	assert.Equal(t, false, got.Value)
+
+	for _, invalid := range []string{"", "this..a", "*.a", "this.items[x]"} {
+		_, err = e.PartialEval(input, invalid)
+		assert.Error(t, err, invalid)
+	}
+}
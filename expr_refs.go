@@ -0,0 +1,365 @@
+package expr
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+)
+
+// References 表达式静态引用的变量、字段和函数，均按首次出现的顺序排列
+type References struct {
+	Variables []string
+	Fields    []*FieldRef
+	Functions []*FunctionRef
+}
+
+// FieldRef 表达式读取或检测的字段路径
+type FieldRef struct {
+	// Path 字段路径，如 this.P1.X；常量下标记为 this.items[0]，推导式遍历的元素记为 this.items[*]
+	Path string
+	// Guarded 字段的每一次读取都位于 has(Path) 成立的分支中，如 has(this.a) && this.a > 0
+	Guarded bool
+	// HasTest 字段是否在 has() 中被检测
+	HasTest bool
+}
+
+// FunctionRef 表达式调用的函数
+type FunctionRef struct {
+	Name string
+	// Overloads 调用匹配的重载，只有经过类型检查的表达式才有
+	Overloads []string
+}
+
+// ParsedExpr 仅经过语法解析、尚未类型检查的表达式
+type ParsedExpr struct {
+	env *Env
+	ast *cel.Ast
+}
+
+// Parse 解析表达式但不做类型检查，可在变量和函数尚未声明时分析表达式的引用
+func Parse(expression string, env ...*Env) (*ParsedExpr, error) {
+	_env, err := selectEnv(env)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := _env.env.Parse(expression)
+	if issues.Err() != nil {
+		return nil, newCompileError(common.NewTextSource(expression), issues, true)
+	}
+	return &ParsedExpr{env: _env, ast: ast}, nil
+}
+
+// Check 对表达式做类型检查并创建可执行的表达式
+func (p *ParsedExpr) Check() (*Expr, error) {
+	ast, issues := p.env.check(p.ast)
+	if issues.Err() != nil {
+		return nil, newCompileError(p.ast.Source(), issues, false)
+	}
+	return newExpr(p.env, ast)
+}
+
+// References 返回表达式引用的变量、字段和函数，未经类型检查时变量名不会按容器解析，也没有函数重载
+func (p *ParsedExpr) References() *References {
+	return collectReferences(p.ast.NativeRep())
+}
+
+// References 返回表达式引用的变量、字段和函数
+func (e *Expr) References() *References {
+	return collectReferences(e.ast.NativeRep())
+}
+
+func collectReferences(a *ast.AST) *References {
+	c := &refCollector{
+		refs:   a.ReferenceMap(),
+		vars:   map[string]struct{}{},
+		fields: map[string]*FieldRef{},
+		read:   map[string]bool{},
+		funcs:  map[string]*FunctionRef{},
+	}
+	c.walk(a.Expr(), nil, nil)
+	for _, f := range c.result.Fields {
+		f.Guarded = c.read[f.Path] && f.Guarded
+	}
+	return &c.result
+}
+
+type refCollector struct {
+	refs   map[int64]*ast.ReferenceInfo
+	result References
+	vars   map[string]struct{}
+	fields map[string]*FieldRef
+	// read 字段是否被读取，仅在 has() 中检测的字段不算读取
+	read  map[string]bool
+	funcs map[string]*FunctionRef
+}
+
+// scope 推导式中的局部变量，值为其对应的字段路径，为空表示局部变量不对应输入的字段
+type scope map[string]string
+
+// guards has() 检查成立的字段路径
+type guards map[string]struct{}
+
+func (c *refCollector) walk(e ast.Expr, sc scope, gs guards) {
+	switch e.Kind() {
+	case ast.IdentKind, ast.SelectKind:
+		if e.Kind() == ast.SelectKind && e.AsSelect().IsTestOnly() {
+			c.walkHas(e, sc, gs)
+			return
+		}
+		if path, depth, ok := c.pathOf(e, sc); ok {
+			if depth > 0 {
+				c.addField(path, gs)
+			}
+			return
+		}
+		if e.Kind() == ast.SelectKind {
+			c.walk(e.AsSelect().Operand(), sc, gs)
+		}
+	case ast.CallKind:
+		c.walkCall(e, sc, gs)
+	case ast.ComprehensionKind:
+		c.walkComprehension(e, sc, gs)
+	case ast.ListKind:
+		for _, elem := range e.AsList().Elements() {
+			c.walk(elem, sc, gs)
+		}
+	case ast.MapKind:
+		for _, entry := range e.AsMap().Entries() {
+			c.walk(entry.AsMapEntry().Key(), sc, gs)
+			c.walk(entry.AsMapEntry().Value(), sc, gs)
+		}
+	case ast.StructKind:
+		for _, field := range e.AsStruct().Fields() {
+			c.walk(field.AsStructField().Value(), sc, gs)
+		}
+	}
+}
+
+func (c *refCollector) walkHas(e ast.Expr, sc scope, gs guards) {
+	sel := e.AsSelect()
+	path, depth, ok := c.pathOf(sel.Operand(), sc)
+	if !ok {
+		c.walk(sel.Operand(), sc, gs)
+		return
+	}
+	if depth > 0 {
+		c.addField(path, gs)
+	}
+	c.field(path + "." + sel.FieldName()).HasTest = true
+}
+
+func (c *refCollector) walkCall(e ast.Expr, sc scope, gs guards) {
+	call := e.AsCall()
+	if path, depth, ok := c.pathOf(e, sc); ok {
+		if depth > 0 {
+			c.addField(path, gs)
+		}
+		return
+	}
+	c.addFunction(e.ID(), call.FunctionName())
+	args := call.Args()
+	switch call.FunctionName() {
+	case operators.LogicalAnd:
+		c.walk(args[0], sc, gs)
+		c.walk(args[1], sc, gs.with(c.hasTests(args[0], sc, false)))
+		return
+	case operators.LogicalOr:
+		c.walk(args[0], sc, gs)
+		c.walk(args[1], sc, gs.with(c.hasTests(args[0], sc, true)))
+		return
+	case operators.Conditional:
+		c.walk(args[0], sc, gs)
+		c.walk(args[1], sc, gs.with(c.hasTests(args[0], sc, false)))
+		c.walk(args[2], sc, gs.with(c.hasTests(args[0], sc, true)))
+		return
+	}
+	if call.IsMemberFunction() {
+		c.walk(call.Target(), sc, gs)
+	}
+	for _, arg := range args {
+		c.walk(arg, sc, gs)
+	}
+}
+
+func (c *refCollector) walkComprehension(e ast.Expr, sc scope, gs guards) {
+	comp := e.AsComprehension()
+	c.walk(comp.IterRange(), sc, gs)
+	c.walk(comp.AccuInit(), sc, gs)
+
+	inner := make(scope, len(sc)+3)
+	for k, v := range sc {
+		inner[k] = v
+	}
+	elem := ""
+	if path, _, ok := c.pathOf(comp.IterRange(), sc); ok {
+		elem = path + "[*]"
+	}
+	if comp.HasIterVar2() {
+		inner[comp.IterVar()] = ""
+		inner[comp.IterVar2()] = elem
+	} else {
+		inner[comp.IterVar()] = elem
+	}
+	inner[comp.AccuVar()] = ""
+	c.walk(comp.LoopCondition(), inner, gs)
+	c.walk(comp.LoopStep(), inner, gs)
+	c.walk(comp.Result(), inner, gs)
+}
+
+// pathOf 返回由变量、字段选择和常量下标组成的表达式的字段路径，depth 为变量之后的层级数
+func (c *refCollector) pathOf(e ast.Expr, sc scope) (path string, depth int, ok bool) {
+	if e.Kind() == ast.IdentKind {
+		if local, found := sc[e.AsIdent()]; found {
+			if local == "" {
+				return "", 0, false
+			}
+			return local, 0, true
+		}
+	}
+	if ref, found := c.refs[e.ID()]; found && e.Kind() != ast.CallKind {
+		// 类型检查后，a.b.c 形式的限定变量名记录在引用表中
+		if ref.Name == "" || ref.Value != nil || len(ref.OverloadIDs) > 0 {
+			return "", 0, false
+		}
+		c.addVariable(ref.Name)
+		return ref.Name, 0, true
+	}
+	switch e.Kind() {
+	case ast.IdentKind:
+		c.addVariable(e.AsIdent())
+		return e.AsIdent(), 0, true
+	case ast.SelectKind:
+		sel := e.AsSelect()
+		if sel.IsTestOnly() {
+			return "", 0, false
+		}
+		path, depth, ok := c.pathOf(sel.Operand(), sc)
+		if !ok {
+			return "", 0, false
+		}
+		return path + "." + sel.FieldName(), depth + 1, true
+	case ast.CallKind:
+		call := e.AsCall()
+		args := call.Args()
+		if call.FunctionName() != operators.Index || len(args) != 2 || args[1].Kind() != ast.LiteralKind {
+			return "", 0, false
+		}
+		path, depth, ok := c.pathOf(args[0], sc)
+		if !ok {
+			return "", 0, false
+		}
+		c.addFunction(e.ID(), call.FunctionName())
+		switch key := args[1].AsLiteral().(type) {
+		case types.String:
+			return fmt.Sprintf("%s[%q]", path, string(key)), depth + 1, true
+		case types.Int, types.Uint:
+			return fmt.Sprintf("%s[%v]", path, key.Value()), depth + 1, true
+		}
+	}
+	return "", 0, false
+}
+
+func (c *refCollector) addVariable(name string) {
+	if _, ok := c.vars[name]; ok {
+		return
+	}
+	c.vars[name] = struct{}{}
+	c.result.Variables = append(c.result.Variables, name)
+}
+
+func (c *refCollector) addField(path string, gs guards) {
+	f := c.field(path)
+	guarded := gs.guard(path)
+	if !c.read[path] {
+		c.read[path], f.Guarded = true, guarded
+		return
+	}
+	f.Guarded = f.Guarded && guarded
+}
+
+func (c *refCollector) field(path string) *FieldRef {
+	f, ok := c.fields[path]
+	if !ok {
+		f = &FieldRef{Path: path}
+		c.fields[path] = f
+		c.result.Fields = append(c.result.Fields, f)
+	}
+	return f
+}
+
+func (c *refCollector) addFunction(id int64, name string) {
+	// 宏展开时生成的内部函数不是表达式的作者调用的
+	if name == "@not_strictly_false" {
+		return
+	}
+	f, ok := c.funcs[name]
+	if !ok {
+		f = &FunctionRef{Name: name}
+		c.funcs[name] = f
+		c.result.Functions = append(c.result.Functions, f)
+	}
+	ref, ok := c.refs[id]
+	if !ok {
+		return
+	}
+	for _, o := range ref.OverloadIDs {
+		if !slices.Contains(f.Overloads, o) {
+			f.Overloads = append(f.Overloads, o)
+		}
+	}
+}
+
+// hasTests 返回 e 为 true 时（negated 为 true 则是 e 为 false 时）必然成立的 has() 检测路径
+func (c *refCollector) hasTests(e ast.Expr, sc scope, negated bool) []string {
+	switch e.Kind() {
+	case ast.SelectKind:
+		sel := e.AsSelect()
+		if negated || !sel.IsTestOnly() {
+			return nil
+		}
+		if path, _, ok := c.pathOf(sel.Operand(), sc); ok {
+			return []string{path + "." + sel.FieldName()}
+		}
+	case ast.CallKind:
+		call := e.AsCall()
+		args := call.Args()
+		switch {
+		case call.FunctionName() == operators.LogicalNot:
+			return c.hasTests(args[0], sc, !negated)
+		case call.FunctionName() == operators.LogicalAnd && !negated,
+			call.FunctionName() == operators.LogicalOr && negated:
+			return append(c.hasTests(args[0], sc, negated), c.hasTests(args[1], sc, negated)...)
+		}
+	}
+	return nil
+}
+
+func (gs guards) with(paths []string) guards {
+	if len(paths) == 0 {
+		return gs
+	}
+	out := make(guards, len(gs)+len(paths))
+	for k := range gs {
+		out[k] = struct{}{}
+	}
+	for _, p := range paths {
+		out[p] = struct{}{}
+	}
+	return out
+}
+
+// guard 判断 path 是否受 has() 保护，has(a.b.c) 成立时 a.b 也必然存在
+func (gs guards) guard(path string) bool {
+	for g := range gs {
+		if g == path || strings.HasPrefix(g, path+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zhijingtech/expr/testdata"
+)
+
+func TestExpr_References(t *testing.T) {
+	env, err := NewEnv(
+		Types(&testdata.Rectangle{}),
+		Variable("current", ObjectType("testdata.Rectangle")),
+		Variable("prev", ObjectType("testdata.Rectangle")),
+	)
+	assert.NoError(t, err)
+	e, err := NewExpr(exprstr, env)
+	assert.NoError(t, err)
+
+	refs := e.References()
+	assert.Equal(t, []string{"current", "prev"}, refs.Variables)
+	assert.Equal(t, []*FieldRef{
+		{Path: "current.P1.X"},
+		{Path: "prev.P2.X"},
+		{Path: "current.P1.Y"},
+		{Path: "prev.P2.Y"},
+	}, refs.Fields)
+	assert.Equal(t, []*FunctionRef{
+		{Name: "_||_", Overloads: []string{"logical_or"}},
+		{Name: "_<=_", Overloads: []string{"less_equals_double"}},
+		{Name: "_-_", Overloads: []string{"subtract_double"}},
+	}, refs.Functions)
+}
+
+func TestExpr_References_Guarded(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		want       []*FieldRef
+	}{
+		{
+			name:       "guarded by and",
+			expression: "has(this.v1) && this.v1 > 0",
+			want:       []*FieldRef{{Path: "this.v1", Guarded: true, HasTest: true}},
+		},
+		{
+			name:       "not guarded",
+			expression: "has(this.v1) || this.v1 > 0",
+			want:       []*FieldRef{{Path: "this.v1", HasTest: true}},
+		},
+		{
+			name:       "guarded by negated or",
+			expression: "!has(this.a.b) || this.a.b > this.c",
+			want: []*FieldRef{
+				{Path: "this.a"},
+				{Path: "this.a.b", Guarded: true, HasTest: true},
+				{Path: "this.c"},
+			},
+		},
+		{
+			name:       "guarded by conditional",
+			expression: `has(this.m) ? this.m["k"] + this.m.x : this.d`,
+			want: []*FieldRef{
+				{Path: "this.m", HasTest: true},
+				{Path: `this.m["k"]`},
+				{Path: "this.m.x"},
+				{Path: "this.d"},
+			},
+		},
+		{
+			name:       "comprehension",
+			expression: "this.items.exists(i, has(i.price) && i.price > this.limit)",
+			want: []*FieldRef{
+				{Path: "this.items"},
+				{Path: "this.items[*].price", Guarded: true, HasTest: true},
+				{Path: "this.limit"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := NewExpr(tt.expression)
+			assert.NoError(t, err)
+			refs := e.References()
+			assert.Equal(t, []string{"this"}, refs.Variables)
+			assert.Equal(t, tt.want, refs.Fields)
+		})
+	}
+}
+
+func TestParse_References(t *testing.T) {
+	p, err := Parse("shake_hands(i.name, you) && x.y[0].z")
+	assert.NoError(t, err)
+	refs := p.References()
+	assert.Equal(t, []string{"i", "you", "x"}, refs.Variables)
+	assert.Equal(t, []*FieldRef{
+		{Path: "i.name"},
+		{Path: "x.y[0].z"},
+	}, refs.Fields)
+	assert.Equal(t, []*FunctionRef{{Name: "_&&_"}, {Name: "shake_hands"}, {Name: "_[_]"}}, refs.Functions)
+
+	_, err = p.Check()
+	assert.Error(t, err)
+
+	p, err = Parse("this.value > 1")
+	assert.NoError(t, err)
+	e, err := p.Check()
+	assert.NoError(t, err)
+	got, err := e.Eval(map[string]any{"this": map[string]any{"value": 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, true, got)
+}
@@ -0,0 +1,237 @@
+package expr
+
+import (
+	"container/list"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+)
+
+// regexCacheSize 动态正则表达式缓存的容量
+const regexCacheSize = 256
+
+type (
+	regexLib struct{}
+
+	// regexFunc 使用编译后的正则表达式执行的函数，args 中第二个参数为正则表达式
+	regexFunc func(re *regexp.Regexp, args []Val) Val
+
+	// regexCall 正则表达式为常量的调用，使用编译表达式时预编译的正则表达式
+	regexCall struct {
+		interpreter.InterpretableCall
+		re *regexp.Regexp
+		fn regexFunc
+	}
+
+	// regexValidator 在类型检查时校验常量正则表达式
+	regexValidator struct{}
+
+	// regexCache 最近使用的动态正则表达式的缓存
+	regexCache struct {
+		mu       sync.Mutex
+		capacity int
+		items    map[string]*list.Element
+		lru      *list.List
+	}
+)
+
+var (
+	// regexFuncs 正则表达式函数的实现，key 为重载 ID
+	regexFuncs = map[string]regexFunc{
+		"regex_extract_string_string":        regexExtract,
+		"regex_extractAll_string_string":     regexExtractAll,
+		"regex_replace_string_string_string": regexReplace,
+		"regex_captures_string_string":       regexCaptures,
+	}
+
+	dynamicRegexps = &regexCache{capacity: regexCacheSize, items: map[string]*list.Element{}, lru: list.New()}
+)
+
+// RegexFunctions 正则表达式函数，正则表达式的语法同 Go 的 regexp 包：
+//   - regex.extract(s, pattern)：返回第一个匹配，存在捕获组时返回第一个捕获组，不匹配时返回空字符串；
+//   - regex.extractAll(s, pattern)：返回所有匹配，存在捕获组时返回每个匹配的第一个捕获组；
+//   - regex.replace(s, pattern, replacement)：替换所有匹配，replacement 中可以使用 $1、${name} 引用捕获组；
+//   - regex.captures(s, pattern)：返回第一个匹配中命名捕获组的名称到值的 map，不匹配时返回空 map。
+//
+// 常量正则表达式在编译表达式时校验和预编译，无效时返回包含位置的编译错误；动态正则表达式编译后缓存最近使用的 256 个。
+func RegexFunctions() Option {
+	return cel.Lib(regexLib{})
+}
+
+func (regexLib) LibraryName() string {
+	return "expr.regex"
+}
+
+func (regexLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		Function("regex.extract", Overload("regex_extract_string_string", []*Type{StringType, StringType}, StringType,
+			FunctionBinding(dynamicRegexFunc(regexExtract)))),
+		Function("regex.extractAll", Overload("regex_extractAll_string_string", []*Type{StringType, StringType}, ListType(StringType),
+			FunctionBinding(dynamicRegexFunc(regexExtractAll)))),
+		Function("regex.replace", Overload("regex_replace_string_string_string", []*Type{StringType, StringType, StringType}, StringType,
+			FunctionBinding(dynamicRegexFunc(regexReplace)))),
+		Function("regex.captures", Overload("regex_captures_string_string", []*Type{StringType, StringType}, MapType(StringType, StringType),
+			FunctionBinding(dynamicRegexFunc(regexCaptures)))),
+		cel.ASTValidators(regexValidator{}),
+	}
+}
+
+func (regexLib) ProgramOptions() []cel.ProgramOption {
+	return []cel.ProgramOption{cel.CustomDecorator(decorateRegex)}
+}
+
+func (regexValidator) Name() string {
+	return "expr.regex.validator"
+}
+
+// Validate 校验常量正则表达式，错误位置指向正则表达式参数
+func (regexValidator) Validate(_ *cel.Env, _ cel.ValidatorConfig, a *ast.AST, iss *cel.Issues) {
+	calls := ast.MatchDescendants(ast.NavigateAST(a), func(e ast.NavigableExpr) bool {
+		return e.Kind() == ast.CallKind && len(e.AsCall().Args()) > 1 && regexFuncs[regexOverloadID(a, e)] != nil
+	})
+	for _, call := range calls {
+		arg := call.AsCall().Args()[1]
+		if arg.Kind() != ast.LiteralKind {
+			continue
+		}
+		pattern, ok := arg.AsLiteral().(types.String)
+		if !ok {
+			continue
+		}
+		if _, err := regexp.Compile(string(pattern)); err != nil {
+			iss.ReportErrorAtID(arg.ID(), "%s", invalidRegexMessage(string(pattern), err))
+		}
+	}
+}
+
+// regexOverloadID 返回调用经类型检查后唯一确定的重载 ID
+func regexOverloadID(a *ast.AST, e ast.Expr) string {
+	if ref, found := a.ReferenceMap()[e.ID()]; found && len(ref.OverloadIDs) == 1 {
+		return ref.OverloadIDs[0]
+	}
+	return ""
+}
+
+func invalidRegexMessage(pattern string, err error) string {
+	return fmt.Sprintf("invalid regex pattern '%s': %v", pattern, err)
+}
+
+// decorateRegex 预编译常量正则表达式
+func decorateRegex(i interpreter.Interpretable) (interpreter.Interpretable, error) {
+	call, ok := i.(interpreter.InterpretableCall)
+	if !ok {
+		return i, nil
+	}
+	fn, found := regexFuncs[call.OverloadID()]
+	if !found {
+		return i, nil
+	}
+	c, ok := call.Args()[1].(interpreter.InterpretableConst)
+	if !ok {
+		return i, nil
+	}
+	pattern, ok := c.Value().(types.String)
+	if !ok {
+		return i, nil
+	}
+	re, err := regexp.Compile(string(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("%s", invalidRegexMessage(string(pattern), err))
+	}
+	return &regexCall{InterpretableCall: call, re: re, fn: fn}, nil
+}
+
+func (c *regexCall) Eval(vars interpreter.Activation) Val {
+	args := make([]Val, len(c.Args()))
+	for i, arg := range c.Args() {
+		v := arg.Eval(vars)
+		if types.IsUnknownOrError(v) {
+			return v
+		}
+		args[i] = v
+	}
+	return types.LabelErrNode(c.ID(), markFunctionErr(c.fn(c.re, args)))
+}
+
+// dynamicRegexFunc 返回执行时编译正则表达式的函数
+func dynamicRegexFunc(fn regexFunc) FunctionOp {
+	return func(args ...Val) Val {
+		pattern := string(args[1].(types.String))
+		re, err := dynamicRegexps.get(pattern)
+		if err != nil {
+			return types.NewErr("%s", invalidRegexMessage(pattern, err))
+		}
+		return fn(re, args)
+	}
+}
+
+func (c *regexCache) get(pattern string) (*regexp.Regexp, error) {
+	c.mu.Lock()
+	if elem, ok := c.items[pattern]; ok {
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*regexp.Regexp), nil
+	}
+	c.mu.Unlock()
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.items[pattern]; !ok {
+		c.items[pattern] = c.lru.PushFront(re)
+		if c.lru.Len() > c.capacity {
+			oldest := c.lru.Remove(c.lru.Back()).(*regexp.Regexp)
+			delete(c.items, oldest.String())
+		}
+	}
+	return re, nil
+}
+
+func regexExtract(re *regexp.Regexp, args []Val) Val {
+	m := re.FindStringSubmatch(string(args[0].(types.String)))
+	switch {
+	case m == nil:
+		return types.String("")
+	case len(m) > 1:
+		return types.String(m[1])
+	default:
+		return types.String(m[0])
+	}
+}
+
+func regexExtractAll(re *regexp.Regexp, args []Val) Val {
+	matches := re.FindAllStringSubmatch(string(args[0].(types.String)), -1)
+	result := make([]string, len(matches))
+	for i, m := range matches {
+		if len(m) > 1 {
+			result[i] = m[1]
+		} else {
+			result[i] = m[0]
+		}
+	}
+	return types.NewStringList(types.DefaultTypeAdapter, result)
+}
+
+func regexReplace(re *regexp.Regexp, args []Val) Val {
+	return types.String(re.ReplaceAllString(string(args[0].(types.String)), string(args[2].(types.String))))
+}
+
+func regexCaptures(re *regexp.Regexp, args []Val) Val {
+	captures := map[string]string{}
+	if m := re.FindStringSubmatch(string(args[0].(types.String))); m != nil {
+		for i, name := range re.SubexpNames() {
+			if name != "" {
+				captures[name] = m[i]
+			}
+		}
+	}
+	return types.NewStringStringMap(types.DefaultTypeAdapter, captures)
+}
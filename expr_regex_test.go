@@ -0,0 +1,87 @@
+package expr
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegexFunctions(t *testing.T) {
+	env, err := DefaultEnv.Extend(RegexFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	input := WrapThisVariable(map[string]any{"text": "order-123, order-456", "pattern": `order-(\d+)`})
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{`regex.extract(this.text, "[0-9]+")`, "123"},
+		{`regex.extract(this.text, "order-([0-9]+)")`, "123"},
+		{`regex.extract(this.text, "invoice")`, ""},
+		{`regex.extract(this.text, this.pattern)`, "123"},
+		{`regex.extractAll(this.text, "order-([0-9]+)")`, []string{"123", "456"}},
+		{`regex.extractAll(this.text, "[a-z]+")`, []string{"order", "order"}},
+		{`regex.extractAll(this.text, "x")`, []string{}},
+		{`regex.replace(this.text, "order-([0-9]+)", "#$1")`, "#123, #456"},
+		{`regex.replace(this.text, this.pattern, "${1}!")`, "123!, 456!"},
+		{`regex.captures("2024-03-15", "(?P<year>\\d+)-(?P<month>\\d+)-(\\d+)")`, map[string]string{"year": "2024", "month": "03"}},
+		{`regex.captures("none", "(?P<year>\\d+)")`, map[string]string{}},
+		{`regex.captures(this.text, "order-(?P<id>\\d+)").id == "123"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRegexFunctionsInvalidPattern(t *testing.T) {
+	env, err := DefaultEnv.Extend(RegexFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = NewExpr(`regex.extract(this.text, "(")`, env)
+	var compileErr *CompileError
+	if assert.True(t, errors.As(err, &compileErr)) && assert.Len(t, compileErr.Issues, 1) {
+		issue := compileErr.Issues[0]
+		assert.Equal(t, CodeInvalidRegex, issue.Code)
+		assert.Equal(t, 1, issue.Line)
+		assert.Equal(t, 25, issue.Column)
+		assert.Contains(t, issue.Message, "invalid regex pattern '(': error parsing regexp")
+	}
+
+	e, err := NewExpr(`regex.replace("a", this.pattern, "b")`, env)
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = e.Eval(WrapThisVariable(map[string]any{"pattern": "("}))
+	assert.ErrorIs(t, err, ErrFunction)
+	assert.ErrorContains(t, err, "invalid regex pattern '(':")
+}
+
+func TestRegexCache(t *testing.T) {
+	cache := &regexCache{capacity: 2, items: map[string]*list.Element{}, lru: list.New()}
+	for i := 0; i < 3; i++ {
+		_, err := cache.get(fmt.Sprintf("a%d", i))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 2, cache.lru.Len())
+	assert.NotContains(t, cache.items, "a0")
+	re, err := cache.get("a2")
+	assert.NoError(t, err)
+	assert.Same(t, cache.items["a2"].Value, re)
+
+	_, err = cache.get("(")
+	assert.Error(t, err)
+	assert.Equal(t, 2, cache.lru.Len())
+}
@@ -0,0 +1,157 @@
+package expr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Rule 规则集中的一条规则，Expression 必须是输出为 bool 的表达式
+type Rule struct {
+	ID         string
+	Expression string
+	Priority   int            // 优先级，数值越大越优先，仅在 MatchPriority 和 MatchAllByPriority 模式下影响执行顺序
+	Metadata   map[string]any // 规则的附加信息，规则集不做解释
+	Action     any            // 规则命中后由调用方执行的动作，规则集不做解释
+}
+
+// MatchMode 规则集的匹配模式
+type MatchMode int
+
+const (
+	// MatchAll 按声明顺序执行全部规则，返回所有命中的规则
+	MatchAll MatchMode = iota
+	// MatchFirst 按声明顺序执行规则，返回第一条命中的规则
+	MatchFirst
+	// MatchPriority 按优先级从高到低执行规则（优先级相同时按声明顺序），返回第一条命中的规则，
+	// 即按优先级排序后的 MatchFirst
+	MatchPriority
+	// MatchAllByPriority 按优先级从高到低执行全部规则（优先级相同时按声明顺序），按此顺序返回所有命中的规则
+	MatchAllByPriority
+)
+
+// RuleSet 基于同一个 Env 编译的一组规则，并发安全
+type RuleSet struct {
+	rules []*compiledRule
+	// byPriority 按优先级排序的规则，供 MatchPriority 和 MatchAllByPriority 模式使用
+	byPriority []*compiledRule
+}
+
+type compiledRule struct {
+	rule *Rule
+	expr *TypedExpr[bool]
+}
+
+// RuleSetResult 规则集的执行结果，单条规则执行失败不会中断其他规则的执行
+type RuleSetResult struct {
+	Matches   []*Rule      // 命中规则的副本，按执行顺序排列
+	Errors    []*RuleError // 执行失败的规则
+	Evaluated int          // 实际执行的规则数量
+}
+
+// Matched 返回是否有规则命中
+func (r *RuleSetResult) Matched() bool {
+	return len(r.Matches) > 0
+}
+
+// Err 将所有规则的错误合并为一个错误返回，没有错误时返回 nil
+func (r *RuleSetResult) Err() error {
+	errs := make([]error, len(r.Errors))
+	for i, err := range r.Errors {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
+
+// RuleError 单条规则编译或执行时的错误
+type RuleError struct {
+	RuleID string
+	Err    error
+}
+
+func (e *RuleError) Error() string {
+	return fmt.Sprintf("rule %s: %v", e.RuleID, e.Err)
+}
+
+func (e *RuleError) Unwrap() error {
+	return e.Err
+}
+
+// NewRuleSet 使用 env 编译全部规则，任意规则编译失败或 ID 重复都会返回错误
+func NewRuleSet(rules []Rule, env ...*Env) (*RuleSet, error) {
+	rs := &RuleSet{rules: make([]*compiledRule, 0, len(rules))}
+	ids := make(map[string]struct{}, len(rules))
+	var errs []error
+	for i := range rules {
+		rule := rules[i]
+		if _, ok := ids[rule.ID]; ok {
+			errs = append(errs, &RuleError{RuleID: rule.ID, Err: errors.New("duplicated rule id")})
+			continue
+		}
+		ids[rule.ID] = struct{}{}
+		e, err := NewTypedExpr[bool](rule.Expression, env...)
+		if err != nil {
+			errs = append(errs, &RuleError{RuleID: rule.ID, Err: err})
+			continue
+		}
+		rs.rules = append(rs.rules, &compiledRule{rule: &rule, expr: e})
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	rs.byPriority = make([]*compiledRule, len(rs.rules))
+	copy(rs.byPriority, rs.rules)
+	sort.SliceStable(rs.byPriority, func(i, j int) bool {
+		return rs.byPriority[i].rule.Priority > rs.byPriority[j].rule.Priority
+	})
+	return rs, nil
+}
+
+// Rules 返回规则集中规则的副本，按声明顺序排列，修改返回值不影响规则集
+func (rs *RuleSet) Rules() []*Rule {
+	rules := make([]*Rule, len(rs.rules))
+	for i, r := range rs.rules {
+		rules[i] = r.copyRule()
+	}
+	return rules
+}
+
+// copyRule 返回规则的副本，避免调用方修改规则集内部的规则
+func (r *compiledRule) copyRule() *Rule {
+	rule := *r.rule
+	return &rule
+}
+
+func (rs *RuleSet) Eval(input any, mode MatchMode) *RuleSetResult {
+	return rs.EvalContext(context.Background(), input, mode)
+}
+
+// EvalContext 按 mode 执行规则集，ctx 结束后剩余的规则不再执行，正在执行的规则返回中断错误
+func (rs *RuleSet) EvalContext(ctx context.Context, input any, mode MatchMode) *RuleSetResult {
+	rules := rs.rules
+	if mode == MatchPriority || mode == MatchAllByPriority {
+		rules = rs.byPriority
+	}
+	result := &RuleSetResult{}
+	for _, r := range rules {
+		if err := ctx.Err(); err != nil {
+			result.Errors = append(result.Errors, &RuleError{RuleID: r.rule.ID, Err: interruptedErr(err)})
+			break
+		}
+		result.Evaluated++
+		matched, err := r.expr.EvalContext(ctx, input)
+		if err != nil {
+			result.Errors = append(result.Errors, &RuleError{RuleID: r.rule.ID, Err: err})
+			continue
+		}
+		if !matched {
+			continue
+		}
+		result.Matches = append(result.Matches, r.copyRule())
+		if mode == MatchFirst || mode == MatchPriority {
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package expr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleSet_Eval(t *testing.T) {
+	rs, err := NewRuleSet([]Rule{
+		{ID: "small", Expression: "this.amount < 100", Priority: 1},
+		{ID: "vip", Expression: `this.level == "vip"`, Priority: 10, Action: "discount"},
+		{ID: "broken", Expression: "this.missing > 0", Priority: 5},
+		{ID: "large", Expression: "this.amount >= 50", Priority: 10, Metadata: map[string]any{"owner": "risk"}},
+	})
+	assert.NoError(t, err)
+	rules := rs.Rules()
+	assert.Len(t, rules, 4)
+	rules[0].Priority = 100
+	assert.Equal(t, 1, rs.Rules()[0].Priority)
+
+	input := map[string]any{"this": map[string]any{"amount": 80, "level": "vip"}}
+
+	result := rs.Eval(input, MatchAll)
+	assert.Equal(t, []string{"small", "vip", "large"}, ruleIDs(result.Matches))
+	assert.Equal(t, 4, result.Evaluated)
+	assert.Len(t, result.Errors, 1)
+	assert.Equal(t, "broken", result.Errors[0].RuleID)
+	assert.EqualError(t, result.Err(), "rule broken: no such key: missing")
+	assert.Equal(t, "discount", result.Matches[1].Action)
+	result.Matches[0].Priority = 99
+	result.Matches[0].Expression = "false"
+	assert.Equal(t, 1, rs.Rules()[0].Priority)
+	assert.Equal(t, "this.amount < 100", rs.Rules()[0].Expression)
+	assert.Equal(t, []string{"small", "vip", "large"}, ruleIDs(rs.Eval(input, MatchAll).Matches))
+
+	result = rs.Eval(input, MatchFirst)
+	assert.Equal(t, []string{"small"}, ruleIDs(result.Matches))
+	assert.Equal(t, 1, result.Evaluated)
+	assert.NoError(t, result.Err())
+
+	result = rs.Eval(input, MatchPriority)
+	assert.Equal(t, []string{"vip"}, ruleIDs(result.Matches))
+	assert.Equal(t, 1, result.Evaluated)
+
+	input = map[string]any{"this": map[string]any{"amount": 800, "level": "normal"}}
+	result = rs.Eval(input, MatchPriority)
+	assert.Equal(t, []string{"large"}, ruleIDs(result.Matches))
+	assert.Equal(t, 2, result.Evaluated)
+
+	input = map[string]any{"this": map[string]any{"amount": 80, "level": "vip"}}
+	result = rs.Eval(input, MatchAllByPriority)
+	assert.Equal(t, []string{"vip", "large", "small"}, ruleIDs(result.Matches))
+	assert.Equal(t, 4, result.Evaluated)
+	assert.Len(t, result.Errors, 1)
+
+	input = map[string]any{"this": map[string]any{"amount": 800, "level": "normal", "missing": 0}}
+	result = rs.Eval(input, MatchFirst)
+	assert.Equal(t, []string{"large"}, ruleIDs(result.Matches))
+	assert.True(t, result.Matched())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = rs.EvalContext(ctx, input, MatchAll)
+	assert.False(t, result.Matched())
+	assert.Equal(t, 0, result.Evaluated)
+	assert.ErrorIs(t, result.Err(), ErrEvalInterrupted)
+}
+
+func TestNewRuleSet_Err(t *testing.T) {
+	_, err := NewRuleSet([]Rule{
+		{ID: "a", Expression: "this.amount < 100"},
+		{ID: "a", Expression: "this.amount > 100"},
+		{ID: "b", Expression: "this.amount + 1"},
+	})
+	assert.EqualError(t, err, "rule a: duplicated rule id\nrule b: expression output type 'int' is not assignable to bool")
+}
+
+func ruleIDs(rules []*Rule) []string {
+	ids := make([]string, len(rules))
+	for i, r := range rules {
+		ids[i] = r.ID
+	}
+	return ids
+}
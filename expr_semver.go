@@ -0,0 +1,500 @@
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/common/types/traits"
+)
+
+type (
+	// Semver semver() 返回的语义化版本号，比较时忽略 Build
+	Semver struct {
+		Major, Minor, Patch int64
+		Prerelease          []string
+		Build               string
+	}
+
+	// semverRange 版本范围，满足任一比较器集合即满足范围
+	semverRange [][]semverComparator
+
+	// semverComparator 单个比较条件，explicitPre 表示版本号的预发布部分是表达式中写出的，
+	// 而不是展开 ^、~ 等范围时生成的
+	semverComparator struct {
+		op          string
+		v           Semver
+		explicitPre bool
+	}
+
+	// partialVersion 范围中可以省略或使用通配符的版本号，-1 表示通配
+	partialVersion struct {
+		major, minor, patch int64
+		pre                 []string
+	}
+
+	semverLib struct{}
+
+	// semverLiteralValidator 在类型检查时校验 semver() 和 satisfies() 的常量参数
+	semverLiteralValidator struct{}
+)
+
+// SemverType 语义化版本号的类型，支持 <、<=、>、>= 和 == 比较
+var SemverType = types.NewOpaqueType("semver.Version").WithTraits(traits.ComparerType)
+
+// SemverFunctions 语义化版本号函数：
+//   - semver(s)：解析版本号，可以有 v 前缀，省略的次版本号和修订号为 0，如 "v2.3" 即 2.3.0；
+//   - isSemver(s)：字符串能否解析为版本号；
+//   - v.major()、v.minor()、v.patch()、v.prerelease()：版本号的各部分，v.isPrerelease() 判断是否为预发布版本；
+//   - v.satisfies(range)：是否满足 npm 风格的版本范围，如 "^2.3"、"~1.2.0"、">=1.0.0 <2.0.0"、"1.x || >=3"，
+//     预发布版本只满足同一个比较器集合中写出了相同主次修订号的预发布版本的范围；
+//   - string(v)：转换为字符串。
+//
+// 按语义化版本 2.0.0 的规则比较，预发布版本低于对应的正式版本。semver() 和 satisfies() 的常量参数在编译表达式时校验。
+func SemverFunctions() Option {
+	return cel.Lib(semverLib{})
+}
+
+func (semverLib) LibraryName() string {
+	return "expr.semver"
+}
+
+func (semverLib) CompileOptions() []cel.EnvOption {
+	semverArgs := []*Type{SemverType, SemverType}
+	return []cel.EnvOption{
+		Function("semver", Overload("string_to_semver", []*Type{StringType}, SemverType, UnaryBinding(func(s Val) Val {
+			v, err := ParseSemver(string(s.(types.String)))
+			if err != nil {
+				return types.WrapErr(err)
+			}
+			return v
+		}))),
+		Function("isSemver", Overload("isSemver_string", []*Type{StringType}, BoolType, UnaryBinding(func(s Val) Val {
+			_, err := ParseSemver(string(s.(types.String)))
+			return types.Bool(err == nil)
+		}))),
+		Function("string", Overload("semver_to_string", []*Type{SemverType}, StringType, UnaryBinding(func(v Val) Val {
+			return types.String(v.(Semver).String())
+		}))),
+		// 比较运算使用标准库基于 traits.Comparer 的实现，这里只声明重载
+		Function(operators.Less, Overload("less_semver", semverArgs, BoolType)),
+		Function(operators.LessEquals, Overload("less_equals_semver", semverArgs, BoolType)),
+		Function(operators.Greater, Overload("greater_semver", semverArgs, BoolType)),
+		Function(operators.GreaterEquals, Overload("greater_equals_semver", semverArgs, BoolType)),
+		Function("major", MemberOverload("semver_major", []*Type{SemverType}, IntType, UnaryBinding(func(v Val) Val {
+			return types.Int(v.(Semver).Major)
+		}))),
+		Function("minor", MemberOverload("semver_minor", []*Type{SemverType}, IntType, UnaryBinding(func(v Val) Val {
+			return types.Int(v.(Semver).Minor)
+		}))),
+		Function("patch", MemberOverload("semver_patch", []*Type{SemverType}, IntType, UnaryBinding(func(v Val) Val {
+			return types.Int(v.(Semver).Patch)
+		}))),
+		Function("prerelease", MemberOverload("semver_prerelease", []*Type{SemverType}, StringType, UnaryBinding(func(v Val) Val {
+			return types.String(strings.Join(v.(Semver).Prerelease, "."))
+		}))),
+		Function("isPrerelease", MemberOverload("semver_isPrerelease", []*Type{SemverType}, BoolType, UnaryBinding(func(v Val) Val {
+			return types.Bool(len(v.(Semver).Prerelease) > 0)
+		}))),
+		Function("satisfies", MemberOverload("semver_satisfies_string", []*Type{SemverType, StringType}, BoolType,
+			BinaryBinding(func(v, s Val) Val {
+				r, err := parseSemverRange(string(s.(types.String)))
+				if err != nil {
+					return types.WrapErr(err)
+				}
+				return types.Bool(r.contains(v.(Semver)))
+			}))),
+		cel.ASTValidators(semverLiteralValidator{}),
+	}
+}
+
+func (semverLib) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+func (semverLiteralValidator) Name() string {
+	return "expr.semver.validator"
+}
+
+// Validate 校验 semver() 的常量版本号和 satisfies() 的常量范围，错误位置指向参数
+func (semverLiteralValidator) Validate(_ *cel.Env, _ cel.ValidatorConfig, a *ast.AST, iss *cel.Issues) {
+	calls := ast.MatchDescendants(ast.NavigateAST(a), func(e ast.NavigableExpr) bool {
+		if e.Kind() != ast.CallKind || len(e.AsCall().Args()) != 1 {
+			return false
+		}
+		switch e.AsCall().FunctionName() {
+		case "semver":
+			return !e.AsCall().IsMemberFunction()
+		case "satisfies":
+			return e.AsCall().IsMemberFunction()
+		}
+		return false
+	})
+	for _, call := range calls {
+		arg := call.AsCall().Args()[0]
+		if arg.Kind() != ast.LiteralKind {
+			continue
+		}
+		s, ok := arg.AsLiteral().(types.String)
+		if !ok {
+			continue
+		}
+		fn := call.AsCall().FunctionName()
+		var err error
+		if fn == "semver" {
+			_, err = ParseSemver(string(s))
+		} else {
+			_, err = parseSemverRange(string(s))
+		}
+		if err != nil {
+			iss.ReportErrorAtID(arg.ID(), "invalid literal '%s' for %s(): %v", s, fn, err)
+		}
+	}
+}
+
+// ParseSemver 解析语义化版本号，可以有 v 前缀，省略的次版本号和修订号为 0
+func ParseSemver(s string) (Semver, error) {
+	p, err := parsePartialVersion(strings.TrimPrefix(s, "v"), false)
+	if err != nil {
+		return Semver{}, fmt.Errorf("invalid version %q: %w", s, err)
+	}
+	v := p.fill()
+	if _, build, found := strings.Cut(s, "+"); found {
+		v.Build = build
+	}
+	return v, nil
+}
+
+// parsePartialVersion 解析可以省略次版本号和修订号的版本号，wildcard 为 true 时允许 x、X、* 通配符
+func parsePartialVersion(s string, wildcard bool) (partialVersion, error) {
+	p := partialVersion{major: -1, minor: -1, patch: -1}
+	s, build, hasBuild := strings.Cut(s, "+")
+	if hasBuild && !validIdentifiers(build, false) {
+		return p, fmt.Errorf("invalid build metadata %q", build)
+	}
+	core, pre, hasPre := strings.Cut(s, "-")
+	if hasPre {
+		if !validIdentifiers(pre, true) {
+			return p, fmt.Errorf("invalid prerelease %q", pre)
+		}
+		p.pre = strings.Split(pre, ".")
+	}
+	parts := strings.Split(core, ".")
+	if len(parts) > 3 {
+		return p, fmt.Errorf("too many version parts")
+	}
+	nums := []*int64{&p.major, &p.minor, &p.patch}
+	for i, part := range parts {
+		if wildcard && (part == "x" || part == "X" || part == "*") {
+			if hasPre {
+				return p, fmt.Errorf("prerelease with wildcard")
+			}
+			break
+		}
+		n, err := parseVersionNumber(part)
+		if err != nil {
+			return p, err
+		}
+		*nums[i] = n
+	}
+	if hasPre && p.patch < 0 {
+		return p, fmt.Errorf("prerelease requires a full version")
+	}
+	return p, nil
+}
+
+func parseVersionNumber(s string) (int64, error) {
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return 0, fmt.Errorf("invalid version number %q", s)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid version number %q", s)
+	}
+	return n, nil
+}
+
+// validIdentifiers 校验以 . 分隔的预发布或构建标识，预发布中的数字标识不能有前导 0
+func validIdentifiers(s string, pre bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		numeric := true
+		for _, c := range id {
+			switch {
+			case c >= '0' && c <= '9':
+			case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '-':
+				numeric = false
+			default:
+				return false
+			}
+		}
+		if pre && numeric && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+// fill 将省略和通配的部分替换为 0
+func (p partialVersion) fill() Semver {
+	return Semver{Major: max(p.major, 0), Minor: max(p.minor, 0), Patch: max(p.patch, 0), Prerelease: p.pre}
+}
+
+func (v Semver) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if len(v.Prerelease) > 0 {
+		s += "-" + strings.Join(v.Prerelease, ".")
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// compare 按语义化版本 2.0.0 的优先级比较，忽略构建信息
+func (v Semver) compare(o Semver) int {
+	for _, d := range []int64{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case len(v.Prerelease) == 0 && len(o.Prerelease) == 0:
+		return 0
+	case len(v.Prerelease) == 0:
+		return 1
+	case len(o.Prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.Prerelease) && i < len(o.Prerelease); i++ {
+		if c := comparePrerelease(v.Prerelease[i], o.Prerelease[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(int64(len(v.Prerelease) - len(o.Prerelease)))
+}
+
+// comparePrerelease 比较预发布标识，数字标识按数值比较且低于非数字标识
+func comparePrerelease(a, b string) int {
+	an, aErr := strconv.ParseUint(a, 10, 64)
+	bn, bErr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aErr == nil && bErr == nil:
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(n int64) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func (v Semver) Compare(other Val) Val {
+	o, ok := other.(Semver)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(other)
+	}
+	return types.Int(v.compare(o))
+}
+
+func (v Semver) ConvertToNative(typeDesc reflect.Type) (any, error) {
+	switch {
+	case reflect.TypeOf(v).AssignableTo(typeDesc):
+		return v, nil
+	case typeDesc.Kind() == reflect.String:
+		return reflect.ValueOf(v.String()).Convert(typeDesc).Interface(), nil
+	}
+	return nil, fmt.Errorf("type conversion error from '%s' to '%v'", SemverType.TypeName(), typeDesc)
+}
+
+func (v Semver) ConvertToType(typeVal ref.Type) Val {
+	switch typeVal {
+	case SemverType:
+		return v
+	case types.StringType:
+		return types.String(v.String())
+	case types.TypeType:
+		return SemverType
+	}
+	return types.NewErr("type conversion error from '%s' to '%s'", SemverType.TypeName(), typeVal.TypeName())
+}
+
+func (v Semver) Equal(other Val) Val {
+	o, ok := other.(Semver)
+	return types.Bool(ok && v.compare(o) == 0)
+}
+
+func (v Semver) Type() ref.Type {
+	return SemverType
+}
+
+func (v Semver) Value() any {
+	return v
+}
+
+// parseSemverRange 解析 npm 风格的版本范围，|| 分隔的每一部分为以空格分隔的比较器集合
+func parseSemverRange(s string) (semverRange, error) {
+	var r semverRange
+	for _, part := range strings.Split(s, "||") {
+		fields := strings.Fields(part)
+		// 允许运算符和版本号之间有空格，如 ">= 1.2.0"
+		for i := 0; i < len(fields)-1; i++ {
+			if strings.Trim(fields[i], "<>=^~") == "" {
+				fields[i] += fields[i+1]
+				fields = append(fields[:i+1], fields[i+2:]...)
+			}
+		}
+		if len(fields) == 0 {
+			fields = []string{"*"}
+		}
+		var set []semverComparator
+		for _, f := range fields {
+			comparators, err := parseComparator(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q: %w", s, err)
+			}
+			set = append(set, comparators...)
+		}
+		r = append(r, set)
+	}
+	return r, nil
+}
+
+// parseComparator 将单个比较条件展开为只包含 <、<=、>、>=、= 的比较器
+func parseComparator(s string) ([]semverComparator, error) {
+	op := s[:len(s)-len(strings.TrimLeft(s, "<>=^~"))]
+	switch op {
+	case "", "=", "<", "<=", ">", ">=", "^", "~":
+	default:
+		return nil, fmt.Errorf("invalid operator %q", op)
+	}
+	p, err := parsePartialVersion(strings.TrimPrefix(s[len(op):], "v"), true)
+	if err != nil {
+		return nil, err
+	}
+	lower := semverComparator{op: ">=", v: p.fill(), explicitPre: len(p.pre) > 0}
+	anyVersion := []semverComparator{{op: ">=", v: Semver{}}}
+	// upper 返回 < next-0 的上界，不包含 next 的预发布版本
+	upper := func(major, minor, patch int64) semverComparator {
+		return semverComparator{op: "<", v: Semver{Major: major, Minor: minor, Patch: patch, Prerelease: []string{"0"}}}
+	}
+
+	switch op {
+	case "^":
+		switch {
+		case p.major < 0:
+			return anyVersion, nil
+		case p.major > 0 || p.minor < 0:
+			return []semverComparator{lower, upper(p.major+1, 0, 0)}, nil
+		case p.minor > 0 || p.patch < 0:
+			return []semverComparator{lower, upper(0, p.minor+1, 0)}, nil
+		}
+		return []semverComparator{lower, upper(0, 0, p.patch+1)}, nil
+	case "~":
+		switch {
+		case p.major < 0:
+			return anyVersion, nil
+		case p.minor < 0:
+			return []semverComparator{lower, upper(p.major+1, 0, 0)}, nil
+		}
+		return []semverComparator{lower, upper(p.major, p.minor+1, 0)}, nil
+	}
+
+	if p.patch >= 0 {
+		if op == "" {
+			op = "="
+		}
+		return []semverComparator{{op: op, v: p.fill(), explicitPre: len(p.pre) > 0}}, nil
+	}
+	// 省略或通配了部分版本号
+	if p.major < 0 {
+		if op == "<" || op == ">" {
+			// 不存在小于或大于任意版本的版本
+			return []semverComparator{{op: "<", v: Semver{Prerelease: []string{"0"}}}}, nil
+		}
+		return anyVersion, nil
+	}
+	next := upper(p.major+1, 0, 0)
+	if p.minor >= 0 {
+		next = upper(p.major, p.minor+1, 0)
+	}
+	switch op {
+	case "", "=":
+		return []semverComparator{lower, next}, nil
+	case ">":
+		next.op, next.v.Prerelease = ">=", nil
+		return []semverComparator{next}, nil
+	case ">=":
+		return []semverComparator{lower}, nil
+	case "<":
+		lower.op, lower.v.Prerelease = "<", []string{"0"}
+		return []semverComparator{lower}, nil
+	}
+	// <=
+	return []semverComparator{next}, nil
+}
+
+func (r semverRange) contains(v Semver) bool {
+	for _, set := range r {
+		if setContains(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func setContains(set []semverComparator, v Semver) bool {
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+	}
+	if len(v.Prerelease) == 0 {
+		return true
+	}
+	for _, c := range set {
+		if c.explicitPre && c.v.Major == v.Major && c.v.Minor == v.Minor && c.v.Patch == v.Patch {
+			return true
+		}
+	}
+	return false
+}
+
+func (c semverComparator) matches(v Semver) bool {
+	cmp := v.compare(c.v)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
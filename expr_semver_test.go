@@ -0,0 +1,136 @@
+package expr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemverFunctions(t *testing.T) {
+	env, err := DefaultEnv.Extend(SemverFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	input := WrapThisVariable(map[string]any{"app_version": "2.10.0", "beta": "2.4.0-beta.2"})
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{`semver(this.app_version) >= semver("2.3.0")`, true},
+		{`semver(this.app_version) < semver("2.9.9")`, false},
+		{`semver(this.app_version) > semver("v2.9")`, true},
+		{`semver("2.3.0") <= semver("2.3.0+build.5")`, true},
+		{`semver("2.3.0") == semver("2.3.0+build.5")`, true},
+		{`semver("2.3.0") != semver("2.3.1")`, true},
+		{`semver("1.0.0-alpha") < semver("1.0.0-alpha.1")`, true},
+		{`semver("1.0.0-alpha.beta") < semver("1.0.0-beta")`, true},
+		{`semver("1.0.0-beta.2") < semver("1.0.0-beta.11")`, true},
+		{`semver("1.0.0-rc.1") < semver("1.0.0")`, true},
+		{`semver(this.app_version).major()`, int64(2)},
+		{`semver(this.app_version).minor()`, int64(10)},
+		{`semver(this.app_version).patch()`, int64(0)},
+		{`semver(this.beta).prerelease()`, "beta.2"},
+		{`semver(this.beta).isPrerelease()`, true},
+		{`string(semver("v1.2"))`, "1.2.0"},
+		{`string(semver("1.2.3-rc.1+exp.sha"))`, "1.2.3-rc.1+exp.sha"},
+		{`isSemver("1.2.3") && !isSemver("1.2.3.4") && !isSemver("01.2.3")`, true},
+		{`semver(this.app_version).satisfies("^2.3")`, true},
+		{`semver("3.0.0").satisfies("^2.3")`, false},
+		{`semver("0.2.5").satisfies("^0.2.3")`, true},
+		{`semver("0.3.0").satisfies("^0.2.3")`, false},
+		{`semver("0.0.4").satisfies("^0.0.3")`, false},
+		{`semver("1.2.9").satisfies("~1.2.3")`, true},
+		{`semver("1.3.0").satisfies("~1.2.3")`, false},
+		{`semver("1.5.0").satisfies(">=1.2.0 <2.0.0")`, true},
+		{`semver("1.5.0").satisfies(">= 1.2.0 < 1.5.0")`, false},
+		{`semver("1.5.0").satisfies("1.x || >=3")`, true},
+		{`semver("2.5.0").satisfies("1.x || >=3")`, false},
+		{`semver("1.2.3").satisfies("1.2.3")`, true},
+		{`semver("1.2.3").satisfies("*")`, true},
+		{`semver("1.3.0").satisfies(">1.2")`, true},
+		{`semver("1.2.9").satisfies(">1.2")`, false},
+		{`semver("1.2.9").satisfies("<=1.2")`, true},
+		{`semver("1.2.0").satisfies("<1.2")`, false},
+		{`semver(this.beta).satisfies("^2.3")`, false},
+		{`semver(this.beta).satisfies(">=2.4.0-beta.1 <3")`, true},
+		{`semver("2.5.0-beta.1").satisfies(">=2.4.0-beta.1 <3")`, false},
+		{`semver("2.0.0-rc.1").satisfies("<2.0.0")`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	e, err := NewExpr(`semver(this.app_version)`, env)
+	if assert.NoError(t, err) {
+		got, err := e.Eval(input)
+		assert.NoError(t, err)
+		assert.Equal(t, Semver{Major: 2, Minor: 10}, got)
+	}
+}
+
+func TestSemverFunctionsInvalidLiteral(t *testing.T) {
+	env, err := DefaultEnv.Extend(SemverFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	tests := []struct {
+		expression string
+		column     int
+		arg        string
+	}{
+		{`semver("1.2.x")`, 7, "1.2.x"},
+		{`semver(this.v) > semver("1.02")`, 24, "1.02"},
+		{`semver(this.v).satisfies("=>1.0")`, 25, "=>1.0"},
+		{`semver(this.v).satisfies("^1.2.3-")`, 25, "^1.2.3-"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			_, err := NewExpr(tt.expression, env)
+			var compileErr *CompileError
+			if assert.True(t, errors.As(err, &compileErr)) && assert.Len(t, compileErr.Issues, 1) {
+				issue := compileErr.Issues[0]
+				assert.Equal(t, CodeInvalidLiteral, issue.Code)
+				assert.Equal(t, tt.column, issue.Column)
+				assert.Equal(t, tt.arg, issue.Args[0])
+			}
+		})
+	}
+
+	e, err := NewExpr(`semver(this.v) > semver("1.0.0")`, env)
+	if assert.NoError(t, err) {
+		_, err = e.Eval(WrapThisVariable(map[string]any{"v": "latest"}))
+		assert.ErrorIs(t, err, ErrFunction)
+		assert.ErrorContains(t, err, `invalid version "latest"`)
+	}
+}
+
+func TestSemverFunctionsMarshalBinary(t *testing.T) {
+	env, err := DefaultEnv.Extend(SemverFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	e, err := NewExpr(`semver(this.app_version) >= semver("2.3.0") && semver(this.app_version).satisfies("^2")`, env)
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, err := e.MarshalBinary()
+	if !assert.NoError(t, err) {
+		return
+	}
+	loaded, err := UnmarshalExpr(data, env)
+	if !assert.NoError(t, err) {
+		return
+	}
+	got, err := loaded.Eval(WrapThisVariable(map[string]any{"app_version": "2.10.0"}))
+	assert.NoError(t, err)
+	assert.Equal(t, true, got)
+}
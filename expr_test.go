@@ -1,10 +1,12 @@
 package expr
 
 import (
+	"context"
 	"math"
 	"testing"
 	"time"
 
+	"github.com/google/cel-go/cel"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/zhijingtech/expr/testdata"
@@ -418,15 +420,18 @@ func TestExpr_Eval_Err(t *testing.T) {
 	}
 }
 
-func TestExpr_ContextEval(t *testing.T) {
+func TestExpr_EvalContext(t *testing.T) {
 	options := []Option{
 		UseThisVariable(),
-		Function("sleep",
-			Overload("sleep_int_null", []*Type{IntType}, NullType, UnaryBinding(func(arg Val) Val {
-				t := arg.(Int).Value().(int)
-				time.Sleep(time.Millisecond * time.Duration(t))
-				return nil
-			}))),
+		// 耗时的函数通过 ctx 感知取消
+		GoFunction("sleep", func(ctx context.Context, ms int) bool {
+			select {
+			case <-time.After(time.Millisecond * time.Duration(ms)):
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}),
 	}
 
 	env, err := NewEnv(options...)
@@ -435,18 +440,53 @@ func TestExpr_ContextEval(t *testing.T) {
 	expr1, err := NewExpr("this.milliseconds", env)
 	assert.NoError(t, err)
 	assert.NotNil(t, expr1)
-	got, err := expr1.Eval(map[string]any{"this": map[string]any{"milliseconds": 200}})
+	got, err := expr1.EvalContext(context.Background(), map[string]any{"this": map[string]any{"milliseconds": 200}})
 	assert.NoError(t, err)
 	assert.Equal(t, int64(200), got)
 
-	// expr2, err := env.NewExpr("sleep(this.milliseconds)")
-	// assert.NoError(t, err)
-	// assert.NotNil(t, expr2)
-	// ctx, canel := context.WithTimeout(context.Background(), time.Microsecond*100)
-	// defer canel()
-	// got, err = expr2.ContextEval(ctx, map[string]any{"this": map[string]any{"milliseconds": 200}})
-	// assert.NoError(t, err)
-	// assert.Nil(t, got)
+	expr2, err := NewExpr("sleep(this.milliseconds)", env)
+	assert.NoError(t, err)
+	assert.NotNil(t, expr2)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	start := time.Now()
+	got, err = expr2.EvalContext(ctx, map[string]any{"this": map[string]any{"milliseconds": 200}})
+	assert.Less(t, time.Since(start), 150*time.Millisecond)
+	assert.ErrorIs(t, err, ErrEvalInterrupted)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, got)
+
+	expr3, err := NewExpr("this.items.all(x, this.items.all(y, x + y >= 0))", env)
+	assert.NoError(t, err)
+	items := make([]int, 3000)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	got, err = expr3.EvalContext(ctx, map[string]any{"this": map[string]any{"items": items}})
+	assert.ErrorIs(t, err, ErrEvalInterrupted)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, got)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	got, err = expr1.EvalContext(ctx, map[string]any{"this": map[string]any{"milliseconds": 200}})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Nil(t, got)
+}
+
+func TestFromCelEnv(t *testing.T) {
+	celEnv, err := cel.NewEnv(cel.Variable("x", cel.IntType))
+	assert.NoError(t, err)
+	env := FromCelEnv(celEnv)
+	assert.Same(t, celEnv, env.CelEnv())
+	assert.Nil(t, FromCelEnv(nil))
+
+	e, err := NewExpr("x + 1", env)
+	if assert.NoError(t, err) {
+		got, err := e.Eval(map[string]any{"x": 1})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), got)
+	}
+	assert.True(t, DefaultEnv.CelEnv().HasLibrary("cel.lib.std"))
 }
 
 func TestEnv_Extend(t *testing.T) {
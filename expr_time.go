@@ -0,0 +1,240 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/interpreter"
+)
+
+// timeLibName 时间函数库的名称，用于判断环境中是否引入了时间函数
+const timeLibName = "expr.time"
+
+type (
+	// Clock 时间函数中 now() 使用的时钟，可替换为固定时间，使测试和回放的结果确定
+	Clock interface {
+		Now() time.Time
+	}
+
+	// ClockFunc 将函数转换为 Clock
+	ClockFunc func() time.Time
+
+	clockKey struct{}
+
+	timeLib struct{}
+
+	// nowCall 执行时从 ctx 或环境中获取时钟的 now() 调用
+	nowCall struct {
+		interpreter.InterpretableCall
+		clock Clock
+	}
+)
+
+// SystemClock 返回系统当前时间的时钟
+var SystemClock Clock = ClockFunc(time.Now)
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// FixedClock 返回总是返回 t 的时钟
+func FixedClock(t time.Time) Clock {
+	return ClockFunc(func() time.Time { return t })
+}
+
+// WithClock 返回 now() 使用 clock 的新环境，基于该环境创建的表达式默认使用此时钟
+func (e *Env) WithClock(clock Clock) *Env {
+	ext := e.clone()
+	ext.clock = clock
+	return ext
+}
+
+// ContextWithClock 返回携带时钟的 ctx，通过 EvalContext 执行时 now() 优先使用此时钟
+func ContextWithClock(ctx context.Context, clock Clock) context.Context {
+	return context.WithValue(ctx, clockKey{}, clock)
+}
+
+// TimeFunctions 时间和日期函数：
+//   - now()：当前时间，取自 ContextWithClock、Env.WithClock 设置的时钟，默认为系统时间；
+//   - parseTime(value, layout)、parseTime(value, layout, tz)：按 Go 的时间格式解析时间，未指定时区时为 UTC；
+//   - ts.format(layout)：按 Go 的时间格式格式化时间；
+//   - ts.inTimezone(tz)：转换到 tz 时区，如 "Asia/Shanghai"，不改变表示的时刻；
+//   - ts.truncate(unit)：截断到 "year"、"month"、"week"（周一）、"day"、"hour"、"minute" 或 "second" 的开始；
+//   - ts.isBusinessDay()：是否为工作日（周一至周五）；
+//   - ts.addBusinessDays(n)：增加 n 个工作日，n 为负数时向前计算；
+//   - ts.businessDaysUntil(other)：从 ts 所在日期到 other 所在日期之间的工作日数，包含 ts 所在日期，不包含 other 所在日期。
+//
+// 截断和工作日按时间所在的时区计算，需要时先通过 inTimezone 转换。
+func TimeFunctions() Option {
+	return cel.Lib(timeLib{})
+}
+
+func (timeLib) LibraryName() string {
+	return timeLibName
+}
+
+func (timeLib) CompileOptions() []cel.EnvOption {
+	return []cel.EnvOption{
+		Function("now", Overload("now", nil, TimestampType, FunctionBinding(func(...Val) Val {
+			return types.Timestamp{Time: SystemClock.Now()}
+		}))),
+		Function("parseTime",
+			Overload("parseTime_string_string", []*Type{StringType, StringType}, TimestampType,
+				BinaryBinding(func(value, layout Val) Val {
+					return parseTime(value, layout, types.String("UTC"))
+				})),
+			Overload("parseTime_string_string_string", []*Type{StringType, StringType, StringType}, TimestampType,
+				FunctionBinding(func(args ...Val) Val {
+					return parseTime(args[0], args[1], args[2])
+				})),
+		),
+		Function("format", MemberOverload("timestamp_format_string", []*Type{TimestampType, StringType}, StringType,
+			BinaryBinding(func(ts, layout Val) Val {
+				return types.String(ts.(types.Timestamp).Format(string(layout.(types.String))))
+			}))),
+		Function("inTimezone", MemberOverload("timestamp_inTimezone_string", []*Type{TimestampType, StringType}, TimestampType,
+			BinaryBinding(func(ts, tz Val) Val {
+				loc, err := time.LoadLocation(string(tz.(types.String)))
+				if err != nil {
+					return types.NewErr("inTimezone: %v", err)
+				}
+				return types.Timestamp{Time: ts.(types.Timestamp).In(loc)}
+			}))),
+		Function("truncate", MemberOverload("timestamp_truncate_string", []*Type{TimestampType, StringType}, TimestampType,
+			BinaryBinding(truncateTime))),
+		Function("isBusinessDay", MemberOverload("timestamp_isBusinessDay", []*Type{TimestampType}, BoolType,
+			UnaryBinding(func(ts Val) Val {
+				return types.Bool(isBusinessDay(ts.(types.Timestamp).Time))
+			}))),
+		Function("addBusinessDays", MemberOverload("timestamp_addBusinessDays_int", []*Type{TimestampType, IntType}, TimestampType,
+			BinaryBinding(func(ts, n Val) Val {
+				t, err := addBusinessDays(ts.(types.Timestamp).Time, int64(n.(types.Int)))
+				if err != nil {
+					return types.WrapErr(err)
+				}
+				return types.Timestamp{Time: t}
+			}))),
+		Function("businessDaysUntil", MemberOverload("timestamp_businessDaysUntil_timestamp", []*Type{TimestampType, TimestampType}, IntType,
+			BinaryBinding(func(from, to Val) Val {
+				return types.Int(businessDaysBetween(from.(types.Timestamp).Time, to.(types.Timestamp).Time))
+			}))),
+	}
+}
+
+func (timeLib) ProgramOptions() []cel.ProgramOption {
+	return nil
+}
+
+// decorateClock 使 now() 在执行时使用 ctx 或环境中的时钟
+func (e *Env) decorateClock(i interpreter.Interpretable) (interpreter.Interpretable, error) {
+	if call, ok := i.(interpreter.InterpretableCall); ok && call.OverloadID() == "now" {
+		return &nowCall{InterpretableCall: call, clock: e.clock}, nil
+	}
+	return i, nil
+}
+
+func (c *nowCall) Eval(vars interpreter.Activation) Val {
+	clock := c.clock
+	if v, found := vars.ResolveName(contextVarName); found {
+		if ctxClock, ok := v.(context.Context).Value(clockKey{}).(Clock); ok {
+			clock = ctxClock
+		}
+	}
+	if clock == nil {
+		clock = SystemClock
+	}
+	return types.Timestamp{Time: clock.Now()}
+}
+
+func parseTime(value, layout, tz Val) Val {
+	loc, err := time.LoadLocation(string(tz.(types.String)))
+	if err != nil {
+		return types.NewErr("parseTime: %v", err)
+	}
+	t, err := time.ParseInLocation(string(layout.(types.String)), string(value.(types.String)), loc)
+	if err != nil {
+		return types.NewErr("parseTime: %v", err)
+	}
+	return types.Timestamp{Time: t}
+}
+
+func truncateTime(ts, unit Val) Val {
+	t := ts.(types.Timestamp).Time
+	y, m, d := t.Date()
+	loc := t.Location()
+	switch unit.(types.String) {
+	case "year":
+		t = time.Date(y, time.January, 1, 0, 0, 0, 0, loc)
+	case "month":
+		t = time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	case "week":
+		t = time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case "day":
+		t = time.Date(y, m, d, 0, 0, 0, 0, loc)
+	case "hour":
+		t = time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case "minute":
+		t = time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, loc)
+	case "second":
+		t = time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, loc)
+	default:
+		return types.NewErr("truncate: unsupported unit '%s'", unit)
+	}
+	return types.Timestamp{Time: t}
+}
+
+func isBusinessDay(t time.Time) bool {
+	wd := t.Weekday()
+	return wd != time.Saturday && wd != time.Sunday
+}
+
+// maxBusinessDays addBusinessDays 允许的 n 的最大绝对值，超过时结果必然超出时间戳的范围
+const maxBusinessDays = 10000 * 366
+
+// addBusinessDays 先按每 7 天 5 个工作日整周跳过，再逐天计算剩余的 1 至 5 个工作日，结果超出时间戳的范围时返回错误
+func addBusinessDays(t time.Time, n int64) (time.Time, error) {
+	if n > maxBusinessDays || n < -maxBusinessDays {
+		return time.Time{}, fmt.Errorf("addBusinessDays: %d business days out of range", n)
+	}
+	if n == 0 {
+		return t, nil
+	}
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	weeks := (n - 1) / 5
+	t = t.AddDate(0, 0, step*int(weeks*7))
+	for n -= weeks * 5; n > 0; {
+		t = t.AddDate(0, 0, step)
+		if isBusinessDay(t) {
+			n--
+		}
+	}
+	if y := t.Year(); y < 1 || y > 9999 {
+		return time.Time{}, fmt.Errorf("addBusinessDays: timestamp out of range")
+	}
+	return t, nil
+}
+
+// businessDaysBetween 返回 [from, to) 日期之间的工作日数，to 早于 from 时为负数
+func businessDaysBetween(from, to time.Time) int64 {
+	to = to.In(from.Location())
+	if to.Before(from) {
+		return -businessDaysBetween(to, from)
+	}
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	// time.Duration 只能表示约 292 年，按 Unix 秒数计算天数
+	days := (end.Unix() - start.Unix()) / 86400
+	count := days / 7 * 5
+	for t := start.AddDate(0, 0, int(days/7*7)); t.Before(end); t = t.AddDate(0, 0, 1) {
+		if isBusinessDay(t) {
+			count++
+		}
+	}
+	return count
+}
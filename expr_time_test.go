@@ -0,0 +1,195 @@
+package expr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeFunctions(t *testing.T) {
+	// 2024-03-15 是周五
+	now := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	env, err := DefaultEnv.Extend(TimeFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	env = env.WithClock(FixedClock(now))
+	input := WrapThisVariable(map[string]any{"placed": "2024-03-14 18:00:00"})
+	tests := []struct {
+		expression string
+		want       any
+	}{
+		{`now()`, now},
+		{`now() - parseTime(this.placed, "2006-01-02 15:04:05") < duration("24h")`, true},
+		{`parseTime("2024-03-15 08:00", "2006-01-02 15:04", "Asia/Shanghai") == timestamp("2024-03-15T00:00:00Z")`, true},
+		{`now().format("2006/01/02 15:04")`, "2024/03/15 10:30"},
+		{`now().inTimezone("Asia/Shanghai").format("15:04")`, "18:30"},
+		{`now().inTimezone("Asia/Shanghai") == now()`, true},
+		{`now().truncate("day")`, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)},
+		{`now().truncate("week")`, time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{`now().truncate("month")`, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{`now().truncate("year")`, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{`now().truncate("hour")`, time.Date(2024, 3, 15, 10, 0, 0, 0, time.UTC)},
+		{`now().isBusinessDay()`, true},
+		{`(now() + duration("24h")).isBusinessDay()`, false},
+		{`now().addBusinessDays(1)`, time.Date(2024, 3, 18, 10, 30, 0, 0, time.UTC)},
+		{`now().addBusinessDays(-5)`, time.Date(2024, 3, 8, 10, 30, 0, 0, time.UTC)},
+		{`now().businessDaysUntil(now() + duration("240h"))`, int64(6)},
+		{`(now() + duration("240h")).businessDaysUntil(now())`, int64(-6)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			got, err := e.Eval(input)
+			if assert.NoError(t, err) {
+				if want, ok := tt.want.(time.Time); ok {
+					assert.True(t, want.Equal(got.(time.Time)), "got %v", got)
+					return
+				}
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTimeFunctionsClock(t *testing.T) {
+	env, err := DefaultEnv.Extend(TimeFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	e, err := NewExpr(`now()`, env)
+	if !assert.NoError(t, err) {
+		return
+	}
+	got, err := e.Eval(map[string]any{})
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), got.(time.Time), time.Minute)
+
+	envNow := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	e, err = NewExpr(`now()`, env.WithClock(FixedClock(envNow)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	got, err = e.Eval(map[string]any{})
+	assert.NoError(t, err)
+	assert.True(t, envNow.Equal(got.(time.Time)))
+
+	// ctx 中的时钟优先于环境的时钟
+	ctxNow := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	got, err = e.EvalContext(ContextWithClock(context.Background(), FixedClock(ctxNow)), map[string]any{})
+	assert.NoError(t, err)
+	assert.True(t, ctxNow.Equal(got.(time.Time)))
+
+	got, err = e.EvalContext(context.Background(), map[string]any{})
+	assert.NoError(t, err)
+	assert.True(t, envNow.Equal(got.(time.Time)))
+}
+
+func TestTimeFunctionsErrors(t *testing.T) {
+	env, err := DefaultEnv.Extend(TimeFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	tests := []struct {
+		expression string
+		errMsg     string
+	}{
+		{`parseTime("2024-13-01", "2006-01-02")`, "parseTime: "},
+		{`parseTime("2024-01-01", "2006-01-02", "Mars/Base")`, "parseTime: unknown time zone Mars/Base"},
+		{`now().inTimezone("Mars/Base")`, "inTimezone: unknown time zone Mars/Base"},
+		{`now().truncate("decade")`, "truncate: unsupported unit 'decade'"},
+		{`now().addBusinessDays(2000000000)`, "addBusinessDays: 2000000000 business days out of range"},
+		{`now().addBusinessDays(-9223372036854775807)`, "business days out of range"},
+		{`now().addBusinessDays(3000000)`, "addBusinessDays: timestamp out of range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			e, err := NewExpr(tt.expression, env)
+			if !assert.NoError(t, err) {
+				return
+			}
+			_, err = e.Eval(map[string]any{})
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestTimeFunctionsWithStandardExtensions(t *testing.T) {
+	env, err := StandardEnv.Extend(TimeFunctions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	env = env.WithClock(FixedClock(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)))
+	e, err := NewExpr(`"%s: ".format([now().format("2006-01-02")])`, env)
+	if assert.NoError(t, err) {
+		got, err := e.Eval(map[string]any{})
+		assert.NoError(t, err)
+		assert.Equal(t, "2024-03-15: ", got)
+	}
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	// 与逐天计算的结果一致，包括从周末开始计算的情况
+	naive := func(t time.Time, n int) time.Time {
+		step := 1
+		if n < 0 {
+			step, n = -1, -n
+		}
+		for n > 0 {
+			t = t.AddDate(0, 0, step)
+			if isBusinessDay(t) {
+				n--
+			}
+		}
+		return t
+	}
+	start := time.Date(2024, 3, 9, 10, 0, 0, 0, time.UTC)
+	for day := 0; day < 7; day++ {
+		from := start.AddDate(0, 0, day)
+		for n := -30; n <= 30; n++ {
+			got, err := addBusinessDays(from, int64(n))
+			if assert.NoError(t, err) {
+				assert.Equal(t, naive(from, n), got, "%s %+d", from.Weekday(), n)
+			}
+		}
+	}
+
+	got, err := addBusinessDays(start, 2000000)
+	assert.NoError(t, err)
+	assert.Equal(t, naive(start, 2000000), got)
+}
+
+func TestBusinessDaysBetween(t *testing.T) {
+	// 按星期逐天计数，from 和 to 均为 UTC 零点，跨度超过 time.Duration 能表示的约 292 年
+	naive := func(from, to time.Time) int64 {
+		days := int((to.Unix() - from.Unix()) / 86400)
+		var count int64
+		for i := 0; i < days; i++ {
+			if wd := (int(from.Weekday()) + i) % 7; wd != int(time.Saturday) && wd != int(time.Sunday) {
+				count++
+			}
+		}
+		return count
+	}
+	from := time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, naive(from, to), businessDaysBetween(from, to))
+	assert.Equal(t, -naive(from, to), businessDaysBetween(to, from))
+
+	from = time.Date(1700, 3, 5, 0, 0, 0, 0, time.UTC)
+	to = time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, naive(from, to), businessDaysBetween(from, to))
+
+	env, err := NewEnv(TimeFunctions())
+	assert.NoError(t, err)
+	e, err := NewExpr(`timestamp("0001-01-01T00:00:00Z").businessDaysUntil(timestamp("9999-12-31T00:00:00Z"))`, env)
+	assert.NoError(t, err)
+	got, err := e.Eval(map[string]any{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2608614), got)
+}
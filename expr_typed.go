@@ -0,0 +1,148 @@
+package expr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/google/cel-go/common/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// TypedExpr 输出类型在编译期检查过的表达式，执行结果直接以 T 返回，无需调用方再做类型断言
+type TypedExpr[T any] struct {
+	expr *Expr
+}
+
+var (
+	typeOfTime     = reflect.TypeOf(time.Time{})
+	typeOfDuration = reflect.TypeOf(time.Duration(0))
+	typeOfBytes    = reflect.TypeOf([]byte{})
+	typeOfProto    = reflect.TypeOf((*proto.Message)(nil)).Elem()
+)
+
+// NewTypedExpr 编译表达式并检查其输出类型能否赋值给 T，
+// T 支持 bool、各类整数、浮点数、string、[]byte、time.Time、time.Duration、
+// 元素类型受支持的 slice 和 map、protobuf 消息的指针、通过 NativeTypes 注册的结构体以及 any
+func NewTypedExpr[T any](expression string, env ...*Env) (*TypedExpr[T], error) {
+	typ := reflect.TypeOf((*T)(nil)).Elem()
+	want, err := celTypeOf(typ)
+	if err != nil {
+		return nil, err
+	}
+	e, err := NewExpr(expression, env...)
+	if err != nil {
+		return nil, err
+	}
+	if got := e.ast.OutputType(); !isAssignableType(want, got) {
+		return nil, fmt.Errorf("expression output type '%s' is not assignable to %v", got, typ)
+	}
+	if typ.Kind() != reflect.Interface {
+		e = e.WithDecoder(NativeDecoder(typ))
+	}
+	return &TypedExpr[T]{expr: e}, nil
+}
+
+// Expr 返回底层的表达式
+func (e *TypedExpr[T]) Expr() *Expr {
+	return e.expr
+}
+
+func (e *TypedExpr[T]) Eval(input any) (T, error) {
+	return typedResult[T](e.expr.Eval(input))
+}
+
+func (e *TypedExpr[T]) EvalContext(ctx context.Context, input any) (T, error) {
+	return typedResult[T](e.expr.EvalContext(ctx, input))
+}
+
+func typedResult[T any](v any, err error) (T, error) {
+	var zero T
+	if err != nil || v == nil {
+		return zero, err
+	}
+	t, ok := v.(T)
+	if !ok {
+		return zero, fmt.Errorf("unexpected result type %T, want %T", v, zero)
+	}
+	return t, nil
+}
+
+// celTypeOf 返回 Go 类型对应的 cel 类型
+func celTypeOf(typ reflect.Type) (*Type, error) {
+	switch typ {
+	case typeOfTime:
+		return TimestampType, nil
+	case typeOfDuration:
+		return DurationType, nil
+	case typeOfBytes:
+		return BytesType, nil
+	}
+	if typ.Implements(typeOfProto) && typ.Kind() == reflect.Ptr {
+		msg := reflect.Zero(typ).Interface().(proto.Message)
+		return ObjectType(string(msg.ProtoReflect().Descriptor().FullName())), nil
+	}
+	if typ.Kind() == reflect.Struct || typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct {
+		return NativeObjectType(typ), nil
+	}
+	switch typ.Kind() {
+	case reflect.Interface:
+		return DynType, nil
+	case reflect.Bool:
+		return BoolType, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return IntType, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return UintType, nil
+	case reflect.Float32, reflect.Float64:
+		return DoubleType, nil
+	case reflect.String:
+		return StringType, nil
+	case reflect.Slice, reflect.Array:
+		elem, err := celTypeOf(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return ListType(elem), nil
+	case reflect.Map:
+		key, err := celTypeOf(typ.Key())
+		if err != nil {
+			return nil, err
+		}
+		val, err := celTypeOf(typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return MapType(key, val), nil
+	}
+	return nil, fmt.Errorf("unsupported go type: %v", typ)
+}
+
+// isAssignableType 判断 from 类型的值能否赋值给 to 类型，dyn 在任意一侧都视为可赋值，留待执行时检查
+func isAssignableType(to, from *Type) bool {
+	switch {
+	case isDynOrAny(to) || isDynOrAny(from):
+		return true
+	case from.Kind() == types.NullTypeKind:
+		return to.Kind() == types.StructKind
+	case to.Kind() != from.Kind():
+		return false
+	case to.Kind() == types.StructKind && to.TypeName() != from.TypeName():
+		return false
+	}
+	toParams, fromParams := to.Parameters(), from.Parameters()
+	if len(toParams) != len(fromParams) {
+		return false
+	}
+	for i := range toParams {
+		if !isAssignableType(toParams[i], fromParams[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDynOrAny(t *Type) bool {
+	return t.Kind() == types.DynKind || t.Kind() == types.AnyKind
+}
@@ -0,0 +1,94 @@
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zhijingtech/expr/testdata"
+)
+
+func TestNewTypedExpr(t *testing.T) {
+	input := map[string]any{"this": map[string]any{"value": 80, "items": []int{1, 2, 3}}}
+
+	b, err := NewTypedExpr[bool]("this.value > 60")
+	assert.NoError(t, err)
+	gotBool, err := b.Eval(input)
+	assert.NoError(t, err)
+	assert.True(t, gotBool)
+
+	i, err := NewTypedExpr[int64]("1 + 2")
+	assert.NoError(t, err)
+	gotInt, err := i.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), gotInt)
+
+	s, err := NewTypedExpr[string](`"a" + "b"`)
+	assert.NoError(t, err)
+	gotStr, err := s.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", gotStr)
+
+	l, err := NewTypedExpr[[]any]("this.items.filter(x, x > 1)")
+	assert.NoError(t, err)
+	gotList, err := l.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(2), int64(3)}, gotList)
+
+	m, err := NewTypedExpr[map[string]int64](`{"a": 1}`)
+	assert.NoError(t, err)
+	gotMap, err := m.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int64{"a": 1}, gotMap)
+
+	a, err := NewTypedExpr[any]("this.value")
+	assert.NoError(t, err)
+	gotAny, err := a.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(80), gotAny)
+}
+
+func TestNewTypedExpr_Proto(t *testing.T) {
+	env, err := NewEnv(Types(&testdata.Rectangle{}), Variable("this", ObjectType("testdata.Rectangle")))
+	assert.NoError(t, err)
+	input := map[string]any{"this": &testdata.Rectangle{
+		P1: &testdata.Point{X: 1, Y: 2},
+		P2: &testdata.Point{X: 3, Y: 4},
+	}}
+
+	p, err := NewTypedExpr[*testdata.Point]("this.P2", env)
+	assert.NoError(t, err)
+	got, err := p.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), got.X)
+
+	f, err := NewTypedExpr[float64]("this.P2.X - this.P1.X", env)
+	assert.NoError(t, err)
+	gotFloat, err := f.Eval(input)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(2), gotFloat)
+
+	_, err = NewTypedExpr[*testdata.Rectangle]("this.P2", env)
+	assert.EqualError(t, err, "expression output type 'testdata.Point' is not assignable to *testdata.Rectangle")
+}
+
+func TestNewTypedExpr_Err(t *testing.T) {
+	_, err := NewTypedExpr[bool]("1 + 2")
+	assert.EqualError(t, err, "expression output type 'int' is not assignable to bool")
+
+	_, err = NewTypedExpr[[]string]("[1, 2]")
+	assert.EqualError(t, err, "expression output type 'list(int)' is not assignable to []string")
+
+	_, err = NewTypedExpr[chan int]("1")
+	assert.EqualError(t, err, "unsupported go type: chan int")
+
+	_, err = NewTypedExpr[bool]("dummy")
+	assert.Error(t, err)
+
+	// 输出类型为 dyn 时在执行时检查
+	b, err := NewTypedExpr[bool]("this.value")
+	assert.NoError(t, err)
+	got, err := b.Eval(map[string]any{"this": map[string]any{"value": "x"}})
+	assert.Error(t, err)
+	assert.False(t, got)
+}